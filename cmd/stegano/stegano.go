@@ -5,24 +5,57 @@ package main
 
 import (
 	"bytes"
-	"compress/zlib"
-	"crypto/aes"
-	"crypto/cipher"
 	crand "crypto/rand"
-	"crypto/sha256"
+	"encoding/binary"
 	"flag"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
 	"os"
 
+	"golang.org/x/crypto/argon2"
+
+	"github.com/zanicar/stegano/codec"
 	"github.com/zanicar/stegano/png"
 )
 
+// on-the-wire encryption header layout: a 1-byte format version, a 1-byte
+// KDF identifier (so a future KDF can be added without breaking older
+// steganograms), the Argon2id parameters and the salt. Everything after
+// the header is whatever the selected codec.Cipher produced.
+const (
+	aeadHeaderVersion = 1
+	kdfArgon2id       = 1
+
+	saltSize = 16
+	keySize  = 32
+)
+
+// kdfParams are the Argon2id cost parameters. They are carried alongside
+// the salt in the ciphertext header so Reveal can re-derive the same key
+// even if the defaults change in a later version of this tool.
+type kdfParams struct {
+	time      uint32
+	memoryKiB uint32
+	threads   uint8
+}
+
+// defaultKDFParams returns a profile comparable to the defaults used by
+// other Argon2id-based tools (e.g. Picocrypt): 4 passes over 64MiB, with
+// 4-way parallelism.
+func defaultKDFParams() kdfParams {
+	return kdfParams{time: 4, memoryKiB: 64 * 1024, threads: 4}
+}
+
+func deriveKey(password, salt []byte, params kdfParams) []byte {
+	return argon2.IDKey(password, salt, params.time, params.memoryKiB, params.threads, keySize)
+}
+
 type opts struct {
-	zip bool   // applies compression or decompression
-	key []byte // applies encryption or decryption
+	compressor codec.Compressor // selected by -z / -compress; nil disables compression
+	cipher     codec.Cipher     // selected by -key / -cipher; nil disables encryption
+	key        []byte           // password used for encryption, decryption and pixel permutation
+	kdf        kdfParams        // Argon2id cost parameters used when a new salt is generated
 }
 
 func usage() {
@@ -53,25 +86,33 @@ func conceal(dataFile, inputFile, outputFile string, options opts) error {
 	defer wfh.Close()
 
 	// additional options
-	if options.zip {
-		zdata, err := compress(data)
+	var compressorID, cipherID byte
+
+	if options.compressor != nil {
+		zdata, err := options.compressor.Compress(data)
 		if err != nil {
 			return fmt.Errorf("compress: %w", err)
 		}
 		data = zdata
+		compressorID = options.compressor.ID()
 	}
 
-	if options.key != nil {
-		cdata, err := encrypt(data, options.key)
+	if options.cipher != nil {
+		cdata, err := encrypt(data, options.key, options.kdf, options.cipher)
 		if err != nil {
 			return fmt.Errorf("encrypt: %w", err)
 		}
 		data = cdata
+		cipherID = options.cipher.ID()
 	}
 
+	// prepend the algorithm descriptor so reveal can dispatch to the right
+	// compressor and cipher without being told again which ones were used
+	payload := append([]byte{compressorID, cipherID}, data...)
+
 	// steganography
-	stegano := png.New()
-	if err := stegano.Conceal(data, rfh, wfh); err != nil {
+	stegano := png.New(png.WithKey(options.key))
+	if err := stegano.Conceal(payload, rfh, wfh); err != nil {
 		return fmt.Errorf("conceal: %w", err)
 	}
 
@@ -90,32 +131,41 @@ func reveal(inputFile, outputFile string, options opts) error {
 	buf := new(bytes.Buffer)
 
 	// steganography
-	stegano := png.New()
+	stegano := png.New(png.WithKey(options.key))
 	if err := stegano.Reveal(rfh, buf); err != nil {
 		return fmt.Errorf("reveal: %w", err)
 	}
 
+	revealed := buf.Bytes()
+	if len(revealed) < 2 {
+		return fmt.Errorf("revealed payload too short for algorithm descriptor")
+	}
+	compressorID, cipherID := revealed[0], revealed[1]
+	data := revealed[2:]
+
 	// additional options
-	if options.key != nil {
-		pdata, err := decrypt(buf.Bytes(), options.key)
+	if cipherID != 0 {
+		cph, err := codec.CipherByID(cipherID)
 		if err != nil {
 			return fmt.Errorf("decrypt: %w", err)
 		}
-		buf.Reset()
-		if _, err := buf.Write(pdata); err != nil {
+		pdata, err := decrypt(data, options.key, cph)
+		if err != nil {
 			return fmt.Errorf("decrypt: %w", err)
 		}
+		data = pdata
 	}
 
-	if options.zip {
-		zdata, err := decompress(buf.Bytes())
+	if compressorID != 0 {
+		cmp, err := codec.CompressorByID(compressorID)
 		if err != nil {
 			return fmt.Errorf("decompress: %w", err)
 		}
-		buf.Reset()
-		if _, err := buf.Write(zdata); err != nil {
+		zdata, err := cmp.Decompress(data)
+		if err != nil {
 			return fmt.Errorf("decompress: %w", err)
 		}
+		data = zdata
 	}
 
 	// output file handler (writer)
@@ -125,77 +175,41 @@ func reveal(inputFile, outputFile string, options opts) error {
 	}
 	defer wfh.Close()
 
-	buf.WriteTo(wfh)
+	wfh.Write(data)
 
 	return nil
 }
 
-func compress(data []byte) ([]byte, error) {
+// encrypt derives a key from password with Argon2id, using a freshly
+// generated salt, and seals data with the selected cipher. The returned
+// bytes are the versioned header (KDF parameters and salt) followed by
+// whatever the cipher produced, so decrypt needs nothing but the password
+// and a matching cipher to reverse it.
+func encrypt(data []byte, password []byte, params kdfParams, cph codec.Cipher) ([]byte, error) {
 	var buf bytes.Buffer
 
-	// zip writer
-	zw := zlib.NewWriter(&buf)
-	n, err := zw.Write(data)
-	if err != nil {
-		return nil, err
-	}
-
-	// call Close explicitly to flush any unwritten data to the writer
-	if err := zw.Close(); err != nil {
-		return nil, err
-	}
-
-	log.Printf("%d bytes compressed to %d bytes", n, buf.Len())
-
-	return buf.Bytes(), nil
-}
-
-func decompress(data []byte) ([]byte, error) {
-	// input buffer
-	var ibuf bytes.Buffer
-	ibuf.Write(data)
-
-	// zip reader
-	zr, err := zlib.NewReader(&ibuf)
-	if err != nil {
-		return nil, err
-	}
-	defer zr.Close()
-
-	// copy reader data to output buffer (writer) - prevents data going out of scope
-	var obuf bytes.Buffer
-	if _, err := io.Copy(&obuf, zr); err != nil {
-		return nil, err
+	salt := make([]byte, saltSize)
+	if _, err := crand.Read(salt); err != nil {
+		return nil, fmt.Errorf("salt: %w", err)
 	}
 
-	log.Printf("%d bytes decompressed to %d bytes", len(data), obuf.Len())
-
-	return obuf.Bytes(), nil
-}
-
-func encrypt(data []byte, key []byte) ([]byte, error) {
-	var buf bytes.Buffer
+	key := deriveKey(password, salt, params)
 
-	block, err := aes.NewCipher(key)
+	cd, err := cph.Encrypt(data, key)
 	if err != nil {
 		return nil, err
 	}
 
-	// Galois Counter Mode Block Cipher
-	aesgcm, err := cipher.NewGCM(block)
-	if err != nil {
+	buf.WriteByte(aeadHeaderVersion)
+	buf.WriteByte(kdfArgon2id)
+	if err := binary.Write(&buf, binary.BigEndian, params.time); err != nil {
 		return nil, err
 	}
-
-	// 96-bit nonce
-	nonce := make([]byte, 12)
-	if _, err := crand.Read(nonce); err != nil {
+	if err := binary.Write(&buf, binary.BigEndian, params.memoryKiB); err != nil {
 		return nil, err
 	}
-	buf.Write(nonce)
-
-	// cipher data
-	cd := aesgcm.Seal(data[:0], nonce, data, nil)
+	buf.WriteByte(params.threads)
+	buf.Write(salt)
 	buf.Write(cd)
 
 	log.Printf("%d bytes encrypted to %d bytes", len(data), buf.Len())
@@ -203,24 +217,40 @@ func encrypt(data []byte, key []byte) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func decrypt(data []byte, key []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
+// decrypt reverses encrypt: it reads the versioned header back out of data
+// to recover the KDF parameters and salt, re-derives the key from password
+// and hands the remaining sealed data to cph.
+func decrypt(data []byte, password []byte, cph codec.Cipher) ([]byte, error) {
+	const headerLen = 2 + 4 + 4 + 1 + saltSize
+	if len(data) < headerLen {
+		return nil, fmt.Errorf("ciphertext too short (%v bytes)", len(data))
 	}
 
-	aesgcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, err
+	version := data[0]
+	if version != aeadHeaderVersion {
+		return nil, fmt.Errorf("unsupported header version %d", version)
+	}
+
+	kdfID := data[1]
+	if kdfID != kdfArgon2id {
+		return nil, fmt.Errorf("unsupported KDF id %d", kdfID)
 	}
 
-	// nonce size is 12 bytes, the first 12 bytes of data
-	nonce := data[:12]
-	// cipher data follows after nonce
-	cd := data[12:]
+	offset := 2
+	params := kdfParams{time: binary.BigEndian.Uint32(data[offset : offset+4])}
+	offset += 4
+	params.memoryKiB = binary.BigEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	params.threads = data[offset]
+	offset++
 
-	// plain text bytes
-	ptb, err := aesgcm.Open(nil, nonce, cd, nil)
+	salt := data[offset : offset+saltSize]
+	offset += saltSize
+	cd := data[offset:]
+
+	key := deriveKey(password, salt, params)
+
+	ptb, err := cph.Decrypt(cd, key)
 	if err != nil {
 		return nil, err
 	}
@@ -250,10 +280,22 @@ func main() {
 	flag.StringVar(&outputFile, "out", "", "path to output file (create, overwrite)")
 
 	var fzip bool
-	flag.BoolVar(&fzip, "z", false, "applies zip compression or decompression")
+	flag.BoolVar(&fzip, "z", false, "applies compression or decompression")
+
+	var compressName string
+	flag.StringVar(&compressName, "compress", "zlib", "compression algorithm used with -z (zlib, zstd, lz4)")
 
 	var key string
-	flag.StringVar(&key, "key", "", "key used for encryption, decryption and message authentication (use secure key)")
+	flag.StringVar(&key, "key", "", "password used for encryption, decryption and message authentication (use secure key)")
+
+	var cipherName string
+	flag.StringVar(&cipherName, "cipher", "aes-256-gcm", "AEAD cipher used with -key (aes-256-gcm, chacha20-poly1305, xchacha20-poly1305)")
+
+	defaults := defaultKDFParams()
+	var kdfTime, kdfMemory, kdfParallel uint
+	flag.UintVar(&kdfTime, "kdf-time", uint(defaults.time), "Argon2id time (passes) cost")
+	flag.UintVar(&kdfMemory, "kdf-memory", uint(defaults.memoryKiB), "Argon2id memory cost, in KiB")
+	flag.UintVar(&kdfParallel, "kdf-parallel", uint(defaults.threads), "Argon2id parallelism (threads)")
 
 	// Parse flags
 	flag.Parse()
@@ -273,13 +315,30 @@ func main() {
 
 	// initialize execution options
 	options := opts{
-		zip: fzip,
-		key: nil,
+		kdf: kdfParams{
+			time:      uint32(kdfTime),
+			memoryKiB: uint32(kdfMemory),
+			threads:   uint8(kdfParallel),
+		},
+	}
+
+	if fzip {
+		cmp, err := codec.CompressorByName(compressName)
+		if err != nil {
+			log.SetOutput(os.Stderr)
+			log.Fatal(err)
+		}
+		options.compressor = cmp
 	}
 
 	if key != "" {
-		shaKey := sha256.Sum256([]byte(key))
-		options.key = shaKey[:]
+		cph, err := codec.CipherByName(cipherName)
+		if err != nil {
+			log.SetOutput(os.Stderr)
+			log.Fatal(err)
+		}
+		options.key = []byte(key)
+		options.cipher = cph
 	}
 
 	// Conceal
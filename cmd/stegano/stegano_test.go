@@ -0,0 +1,88 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/zanicar/stegano/codec"
+)
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	cph, err := codec.CipherByName("aes-256-gcm")
+	if err != nil {
+		t.Fatalf("CipherByName: %v", err)
+	}
+	data := []byte("encrypt/decrypt must roundtrip through the versioned header")
+	password := []byte("correct horse battery staple")
+	params := defaultKDFParams()
+
+	encrypted, err := encrypt(data, password, params, cph)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	decrypted, err := decrypt(encrypted, password, cph)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if !bytes.Equal(decrypted, data) {
+		t.Fatalf("roundtrip mismatch: got %q want %q", decrypted, data)
+	}
+}
+
+func TestDecryptRejectsUnsupportedVersion(t *testing.T) {
+	cph, err := codec.CipherByName("aes-256-gcm")
+	if err != nil {
+		t.Fatalf("CipherByName: %v", err)
+	}
+	password := []byte("correct horse battery staple")
+
+	encrypted, err := encrypt([]byte("data"), password, defaultKDFParams(), cph)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	encrypted[0] = aeadHeaderVersion + 1
+
+	if _, err := decrypt(encrypted, password, cph); err == nil {
+		t.Fatalf("expected error decrypting an unsupported header version, got nil")
+	}
+}
+
+func TestDecryptRejectsUnsupportedKDF(t *testing.T) {
+	cph, err := codec.CipherByName("aes-256-gcm")
+	if err != nil {
+		t.Fatalf("CipherByName: %v", err)
+	}
+	password := []byte("correct horse battery staple")
+
+	encrypted, err := encrypt([]byte("data"), password, defaultKDFParams(), cph)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	encrypted[1] = kdfArgon2id + 1
+
+	if _, err := decrypt(encrypted, password, cph); err == nil {
+		t.Fatalf("expected error decrypting an unsupported KDF id, got nil")
+	}
+}
+
+func TestDecryptRejectsTruncatedHeader(t *testing.T) {
+	cph, err := codec.CipherByName("aes-256-gcm")
+	if err != nil {
+		t.Fatalf("CipherByName: %v", err)
+	}
+	password := []byte("correct horse battery staple")
+
+	encrypted, err := encrypt([]byte("data"), password, defaultKDFParams(), cph)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	const headerLen = 2 + 4 + 4 + 1 + saltSize
+	if _, err := decrypt(encrypted[:headerLen-1], password, cph); err == nil {
+		t.Fatalf("expected error decrypting a truncated header, got nil")
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package codec
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	crand "crypto/rand"
+	"fmt"
+)
+
+const aesGCMNonceSize = 12
+
+// aesGCM implements Cipher using AES-256-GCM.
+type aesGCM struct{}
+
+func init() { registerCipher(aesGCM{}) }
+
+func (aesGCM) ID() byte     { return 1 }
+func (aesGCM) Name() string { return "aes-256-gcm" }
+
+func (aesGCM) Encrypt(data, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aesGCMNonceSize)
+	if _, err := crand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+func (aesGCM) Decrypt(data, key []byte) ([]byte, error) {
+	if len(data) < aesGCMNonceSize {
+		return nil, fmt.Errorf("codec: aes-256-gcm: ciphertext too short")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, cd := data[:aesGCMNonceSize], data[aesGCMNonceSize:]
+	return gcm.Open(nil, nonce, cd, nil)
+}
@@ -0,0 +1,47 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package codec
+
+import (
+	crand "crypto/rand"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// chacha20Poly1305 implements Cipher using ChaCha20-Poly1305 with its
+// standard 12-byte nonce.
+type chacha20Poly1305 struct{}
+
+func init() { registerCipher(chacha20Poly1305{}) }
+
+func (chacha20Poly1305) ID() byte     { return 2 }
+func (chacha20Poly1305) Name() string { return "chacha20-poly1305" }
+
+func (chacha20Poly1305) Encrypt(data, key []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := crand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+func (chacha20Poly1305) Decrypt(data, key []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("codec: chacha20-poly1305: ciphertext too short")
+	}
+
+	nonce, cd := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, cd, nil)
+}
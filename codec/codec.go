@@ -0,0 +1,42 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+// Package codec provides pluggable authenticated encryption and compression
+// algorithms for use by the stegano command line tool. Each implementation
+// registers itself under a CLI-selectable name and a 1-byte ID; the ID is
+// persisted alongside the concealed payload so Reveal can dispatch to the
+// right implementation automatically, without the caller having to specify
+// it again.
+package codec
+
+// Cipher is implemented by an authenticated encryption algorithm.
+type Cipher interface {
+	// ID is the 1-byte identifier persisted alongside a payload encrypted
+	// with this cipher.
+	ID() byte
+
+	// Name is the CLI-facing name used to select this cipher (e.g.
+	// "aes-256-gcm").
+	Name() string
+
+	// Encrypt seals data under key, returning the cipher's nonce followed
+	// by the sealed data.
+	Encrypt(data, key []byte) ([]byte, error)
+
+	// Decrypt reverses Encrypt.
+	Decrypt(data, key []byte) ([]byte, error)
+}
+
+// Compressor is implemented by a compression algorithm.
+type Compressor interface {
+	// ID is the 1-byte identifier persisted alongside a payload compressed
+	// with this algorithm.
+	ID() byte
+
+	// Name is the CLI-facing name used to select this algorithm (e.g.
+	// "zstd").
+	Name() string
+
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
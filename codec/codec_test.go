@@ -0,0 +1,141 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package codec
+
+import (
+	"bytes"
+	"testing"
+)
+
+func testKey(n int) []byte {
+	key := make([]byte, n)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	return key
+}
+
+func TestCiphersRoundTrip(t *testing.T) {
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	for _, name := range []string{"aes-256-gcm", "chacha20-poly1305", "xchacha20-poly1305"} {
+		c, err := CipherByName(name)
+		if err != nil {
+			t.Fatalf("%v: CipherByName: %v", name, err)
+		}
+		key := testKey(32)
+
+		sealed, err := c.Encrypt(data, key)
+		if err != nil {
+			t.Fatalf("%v: Encrypt: %v", name, err)
+		}
+
+		opened, err := c.Decrypt(sealed, key)
+		if err != nil {
+			t.Fatalf("%v: Decrypt: %v", name, err)
+		}
+		if !bytes.Equal(opened, data) {
+			t.Fatalf("%v: roundtrip mismatch: got %q want %q", name, opened, data)
+		}
+	}
+}
+
+func TestCiphersRejectTamperedCiphertext(t *testing.T) {
+	data := []byte("authenticated data must not be tamperable")
+	for _, name := range []string{"aes-256-gcm", "chacha20-poly1305", "xchacha20-poly1305"} {
+		c, err := CipherByName(name)
+		if err != nil {
+			t.Fatalf("%v: CipherByName: %v", name, err)
+		}
+		key := testKey(32)
+
+		sealed, err := c.Encrypt(data, key)
+		if err != nil {
+			t.Fatalf("%v: Encrypt: %v", name, err)
+		}
+		sealed[len(sealed)-1] ^= 0xff
+
+		if _, err := c.Decrypt(sealed, key); err == nil {
+			t.Fatalf("%v: expected error decrypting tampered ciphertext, got nil", name)
+		}
+	}
+}
+
+func TestCompressorsRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("compressible compressible compressible "), 64)
+	for _, name := range []string{"zlib", "zstd", "lz4"} {
+		c, err := CompressorByName(name)
+		if err != nil {
+			t.Fatalf("%v: CompressorByName: %v", name, err)
+		}
+
+		compressed, err := c.Compress(data)
+		if err != nil {
+			t.Fatalf("%v: Compress: %v", name, err)
+		}
+
+		decompressed, err := c.Decompress(compressed)
+		if err != nil {
+			t.Fatalf("%v: Decompress: %v", name, err)
+		}
+		if !bytes.Equal(decompressed, data) {
+			t.Fatalf("%v: roundtrip mismatch: got %q want %q", name, decompressed, data)
+		}
+	}
+}
+
+func TestCipherByIDRoundTrip(t *testing.T) {
+	for _, name := range []string{"aes-256-gcm", "chacha20-poly1305", "xchacha20-poly1305"} {
+		byName, err := CipherByName(name)
+		if err != nil {
+			t.Fatalf("%v: CipherByName: %v", name, err)
+		}
+		byID, err := CipherByID(byName.ID())
+		if err != nil {
+			t.Fatalf("%v: CipherByID(%v): %v", name, byName.ID(), err)
+		}
+		if byID.Name() != name {
+			t.Fatalf("CipherByID(%v) = %v, want %v", byName.ID(), byID.Name(), name)
+		}
+	}
+}
+
+func TestCompressorByIDRoundTrip(t *testing.T) {
+	for _, name := range []string{"zlib", "zstd", "lz4"} {
+		byName, err := CompressorByName(name)
+		if err != nil {
+			t.Fatalf("%v: CompressorByName: %v", name, err)
+		}
+		byID, err := CompressorByID(byName.ID())
+		if err != nil {
+			t.Fatalf("%v: CompressorByID(%v): %v", name, byName.ID(), err)
+		}
+		if byID.Name() != name {
+			t.Fatalf("CompressorByID(%v) = %v, want %v", byName.ID(), byID.Name(), name)
+		}
+	}
+}
+
+func TestCipherByNameUnknown(t *testing.T) {
+	if _, err := CipherByName("rot13"); err == nil {
+		t.Fatalf("expected error for unknown cipher name, got nil")
+	}
+}
+
+func TestCipherByIDUnknown(t *testing.T) {
+	if _, err := CipherByID(255); err == nil {
+		t.Fatalf("expected error for unknown cipher id, got nil")
+	}
+}
+
+func TestCompressorByNameUnknown(t *testing.T) {
+	if _, err := CompressorByName("bzip2"); err == nil {
+		t.Fatalf("expected error for unknown compressor name, got nil")
+	}
+}
+
+func TestCompressorByIDUnknown(t *testing.T) {
+	if _, err := CompressorByID(255); err == nil {
+		t.Fatalf("expected error for unknown compressor id, got nil")
+	}
+}
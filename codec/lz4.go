@@ -0,0 +1,39 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package codec
+
+import (
+	"bytes"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+// lz4Compressor implements Compressor using LZ4.
+type lz4Compressor struct{}
+
+func init() { registerCompressor(lz4Compressor{}) }
+
+func (lz4Compressor) ID() byte     { return 3 }
+func (lz4Compressor) Name() string { return "lz4" }
+
+func (lz4Compressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := lz4.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (lz4Compressor) Decompress(data []byte) ([]byte, error) {
+	zr := lz4.NewReader(bytes.NewReader(data))
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(zr); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package codec
+
+import "fmt"
+
+var (
+	ciphersByName = map[string]Cipher{}
+	ciphersByID   = map[byte]Cipher{}
+
+	compressorsByName = map[string]Compressor{}
+	compressorsByID   = map[byte]Compressor{}
+)
+
+// registerCipher makes c available via CipherByName and CipherByID. It is
+// called from the init function of each cipher implementation.
+func registerCipher(c Cipher) {
+	ciphersByName[c.Name()] = c
+	ciphersByID[c.ID()] = c
+}
+
+// registerCompressor makes c available via CompressorByName and
+// CompressorByID. It is called from the init function of each compressor
+// implementation.
+func registerCompressor(c Compressor) {
+	compressorsByName[c.Name()] = c
+	compressorsByID[c.ID()] = c
+}
+
+// CipherByName looks up a registered Cipher by its CLI-facing name.
+func CipherByName(name string) (Cipher, error) {
+	c, ok := ciphersByName[name]
+	if !ok {
+		return nil, fmt.Errorf("codec: unknown cipher %q", name)
+	}
+	return c, nil
+}
+
+// CipherByID looks up a registered Cipher by its persisted 1-byte ID.
+func CipherByID(id byte) (Cipher, error) {
+	c, ok := ciphersByID[id]
+	if !ok {
+		return nil, fmt.Errorf("codec: unknown cipher id %d", id)
+	}
+	return c, nil
+}
+
+// CompressorByName looks up a registered Compressor by its CLI-facing name.
+func CompressorByName(name string) (Compressor, error) {
+	c, ok := compressorsByName[name]
+	if !ok {
+		return nil, fmt.Errorf("codec: unknown compressor %q", name)
+	}
+	return c, nil
+}
+
+// CompressorByID looks up a registered Compressor by its persisted 1-byte
+// ID.
+func CompressorByID(id byte) (Compressor, error) {
+	c, ok := compressorsByID[id]
+	if !ok {
+		return nil, fmt.Errorf("codec: unknown compressor id %d", id)
+	}
+	return c, nil
+}
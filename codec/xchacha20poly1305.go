@@ -0,0 +1,48 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package codec
+
+import (
+	crand "crypto/rand"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// xchacha20Poly1305 implements Cipher using XChaCha20-Poly1305, whose
+// extended 24-byte nonce is large enough to generate at random without the
+// birthday-bound collision risk that would apply to the 12-byte variants.
+type xchacha20Poly1305 struct{}
+
+func init() { registerCipher(xchacha20Poly1305{}) }
+
+func (xchacha20Poly1305) ID() byte     { return 3 }
+func (xchacha20Poly1305) Name() string { return "xchacha20-poly1305" }
+
+func (xchacha20Poly1305) Encrypt(data, key []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := crand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+func (xchacha20Poly1305) Decrypt(data, key []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("codec: xchacha20-poly1305: ciphertext too short")
+	}
+
+	nonce, cd := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, cd, nil)
+}
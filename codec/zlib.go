@@ -0,0 +1,44 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package codec
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+)
+
+// zlibCompressor implements Compressor using DEFLATE/zlib.
+type zlibCompressor struct{}
+
+func init() { registerCompressor(zlibCompressor{}) }
+
+func (zlibCompressor) ID() byte     { return 1 }
+func (zlibCompressor) Name() string { return "zlib" }
+
+func (zlibCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (zlibCompressor) Decompress(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, zr); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,32 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package codec
+
+import "github.com/klauspost/compress/zstd"
+
+// zstdCompressor implements Compressor using zstd.
+type zstdCompressor struct{}
+
+func init() { registerCompressor(zstdCompressor{}) }
+
+func (zstdCompressor) ID() byte     { return 2 }
+func (zstdCompressor) Name() string { return "zstd" }
+
+func (zstdCompressor) Compress(data []byte) ([]byte, error) {
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Close()
+	return enc.EncodeAll(data, nil), nil
+}
+
+func (zstdCompressor) Decompress(data []byte) ([]byte, error) {
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+	return dec.DecodeAll(data, nil)
+}
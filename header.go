@@ -0,0 +1,112 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package stegano
+
+import (
+	"fmt"
+	"math"
+)
+
+// HeaderCoder implements the length-header framing shared by the PNG and
+// JPEG steganogram formats: a content length is prepended with a single
+// index byte, selected from the slice of byte values that denote how many
+// of the following bytes represent the length (1-4 bytes, covering lengths
+// up to 4GB), so the index byte itself carries no fixed, fingerprintable
+// value.
+type HeaderCoder struct {
+	hcoder [][]uint8
+	hmap   map[uint8]uint8
+}
+
+// NewHeaderCoder returns a ready-to-use HeaderCoder.
+func NewHeaderCoder() *HeaderCoder {
+	hc := &HeaderCoder{}
+	hc.init()
+	return hc
+}
+
+// init divides the range of values of a single byte (0-255) into 4 slices,
+// each representing one, two, three or four bytes. The content length can
+// then be concealed in the target data by encoding an appropriately
+// selected index byte as the first concealed header byte to denote the
+// number of bytes that represent the content length.
+func (hc *HeaderCoder) init() {
+	hc.hcoder = make([][]uint8, 4)
+	hc.hcoder[0] = make([]uint8, 0)
+	hc.hcoder[1] = make([]uint8, 0)
+	hc.hcoder[2] = make([]uint8, 0)
+	hc.hcoder[3] = make([]uint8, 0)
+	hc.hmap = make(map[uint8]uint8)
+
+	for i := 0; i < 256; i++ {
+		switch {
+		case i%4 == 0 && i/4 > 0: // slice representing 4 bytes [4 294 967 296 -> 4GB]
+			hc.hcoder[3] = append(hc.hcoder[3], uint8(i))
+			hc.hmap[uint8(i)] = 3
+		case i%3 == 0 && i/3 > 0: // slice representing 3 bytes [16 777 216 -> 16MB]
+			hc.hcoder[2] = append(hc.hcoder[2], uint8(i))
+			hc.hmap[uint8(i)] = 2
+		case i%2 == 0 && i/2 > 0: // slice representing 2 bytes [65 536 -> 65KB]
+			hc.hcoder[1] = append(hc.hcoder[1], uint8(i))
+			hc.hmap[uint8(i)] = 1
+		case i%1 == 0 && i/1 > 0: // slice representing 1 byte [255]
+			hc.hcoder[0] = append(hc.hcoder[0], uint8(i))
+			hc.hmap[uint8(i)] = 0
+		}
+	}
+}
+
+// HeaderBytes returns the content length dlen's byte representation
+// prepended with an index byte, drawn via randIntn, that denotes the
+// number of bytes used. The returned slice therefore has length n + 1,
+// where n is the minimum number of bytes required to represent dlen.
+// randIntn lets each caller supply its own source of randomness (both the
+// png and jpeg packages pass a crypto/rand-backed one) while sharing this
+// framing logic.
+func (hc *HeaderCoder) HeaderBytes(dlen int, randIntn func(n int) (int, error)) ([]byte, error) {
+	max := int(math.Pow(2, 32))
+	if dlen > max {
+		return nil, fmt.Errorf("%w: length (%v) max (%v)", ErrCapacityMax, dlen, max)
+	}
+
+	bitcount := len(fmt.Sprintf("%08b", dlen))
+	bytecount := bitcount / 8
+	if bitcount%8 > 0 {
+		bytecount++
+	}
+
+	b := make([]byte, bytecount)
+	l := dlen
+	for bi := 0; bi < bytecount; bi++ {
+		b[bi] |= uint8(l & 255)
+		l = l >> 8
+	}
+
+	var coder []uint8
+	switch {
+	case dlen < int(math.Pow(2, 8)):
+		coder = hc.hcoder[0]
+	case dlen < int(math.Pow(2, 16)):
+		coder = hc.hcoder[1]
+	case dlen < int(math.Pow(2, 24)):
+		coder = hc.hcoder[2]
+	case dlen < int(math.Pow(2, 32)):
+		coder = hc.hcoder[3]
+	}
+
+	ri, err := randIntn(len(coder))
+	if err != nil {
+		return nil, fmt.Errorf("header index byte: %w", err)
+	}
+	b = append([]byte{coder[ri]}, b...)
+
+	return b, nil
+}
+
+// ByteCountFor returns one less than the number of subsequent length bytes
+// denoted by an index byte previously produced by HeaderBytes (0 for one
+// length byte, up to 3 for four), so callers add 1 to get the count.
+func (hc *HeaderCoder) ByteCountFor(indexByte byte) uint8 {
+	return hc.hmap[indexByte]
+}
@@ -0,0 +1,178 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package jpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	markerSOI  = 0xd8
+	markerEOI  = 0xd9
+	markerAPP0 = 0xe0
+	markerDQT  = 0xdb
+	markerSOF0 = 0xc0
+	markerDHT  = 0xc4
+	markerSOS  = 0xda
+)
+
+// writeJPEG assembles a baseline, unsubsampled (4:4:4) JPEG file around a
+// pre-entropy-coded scan, using the fixed quantization and Huffman tables
+// this package always writes.
+func writeJPEG(width, height int, scanData []byte) []byte {
+	var buf bytes.Buffer
+
+	buf.Write([]byte{0xff, markerSOI})
+
+	// APP0/JFIF
+	buf.Write([]byte{0xff, markerAPP0, 0x00, 0x10})
+	buf.WriteString("JFIF\x00")
+	buf.Write([]byte{0x01, 0x01, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00})
+
+	lumaQuant := scaleQuantTable(baseLumaQuant, embedQuality)
+	chromaQuant := scaleQuantTable(baseChromaQuant, embedQuality)
+	writeDQT(&buf, 0, lumaQuant)
+	writeDQT(&buf, 1, chromaQuant)
+
+	writeSOF0(&buf, width, height)
+
+	writeDHT(&buf, 0, 0, lumaDCBits, lumaDCVals)
+	writeDHT(&buf, 1, 0, lumaACBits, lumaACVals)
+	writeDHT(&buf, 0, 1, chromaDCBits, chromaDCVals)
+	writeDHT(&buf, 1, 1, chromaACBits, chromaACVals)
+
+	writeSOS(&buf)
+	buf.Write(scanData)
+
+	buf.Write([]byte{0xff, markerEOI})
+
+	return buf.Bytes()
+}
+
+func writeDQT(buf *bytes.Buffer, id byte, quant [64]int) {
+	buf.Write([]byte{0xff, markerDQT, 0x00, 0x43})
+	buf.WriteByte(id)
+	for _, zz := range zigzagOrder {
+		buf.WriteByte(byte(quant[zz]))
+	}
+}
+
+func writeSOF0(buf *bytes.Buffer, width, height int) {
+	buf.Write([]byte{0xff, markerSOF0, 0x00, 0x11})
+	buf.WriteByte(8) // sample precision
+	binary.Write(buf, binary.BigEndian, uint16(height))
+	binary.Write(buf, binary.BigEndian, uint16(width))
+	buf.WriteByte(3) // number of components
+
+	// component id, sampling factors (1x1, no subsampling), quant table id
+	buf.Write([]byte{1, 0x11, 0})
+	buf.Write([]byte{2, 0x11, 1})
+	buf.Write([]byte{3, 0x11, 1})
+}
+
+func writeDHT(buf *bytes.Buffer, class, id byte, bits [16]byte, values []byte) {
+	length := 2 + 1 + 16 + len(values)
+	buf.Write([]byte{0xff, markerDHT})
+	binary.Write(buf, binary.BigEndian, uint16(length))
+	buf.WriteByte(class<<4 | id)
+	buf.Write(bits[:])
+	buf.Write(values)
+}
+
+func writeSOS(buf *bytes.Buffer) {
+	buf.Write([]byte{0xff, markerSOS, 0x00, 0x0c})
+	buf.WriteByte(3) // number of components in scan
+
+	// component id, (DC table id << 4 | AC table id)
+	buf.Write([]byte{1, 0x00})
+	buf.Write([]byte{2, 0x11})
+	buf.Write([]byte{3, 0x11})
+
+	buf.Write([]byte{0x00, 0x3f, 0x00}) // Ss, Se, AhAl
+}
+
+// parseJPEG walks the marker segments of a JPEG file, recovering the
+// image dimensions from its SOF0 segment and the raw (still byte-stuffed)
+// entropy-coded scan data that follows SOS.
+func parseJPEG(data []byte) (width, height int, scanData []byte, err error) {
+	r := bytes.NewReader(data)
+
+	b, err := r.ReadByte()
+	if err != nil || b != 0xff {
+		return 0, 0, nil, fmt.Errorf("jpeg: missing SOI marker")
+	}
+	m, err := r.ReadByte()
+	if err != nil || m != markerSOI {
+		return 0, 0, nil, fmt.Errorf("jpeg: missing SOI marker")
+	}
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, nil, fmt.Errorf("jpeg: truncated before SOS: %w", err)
+		}
+		if b != 0xff {
+			return 0, 0, nil, fmt.Errorf("jpeg: expected marker, got 0x%02x", b)
+		}
+		marker, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, nil, fmt.Errorf("jpeg: truncated marker: %w", err)
+		}
+
+		var length uint16
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return 0, 0, nil, fmt.Errorf("jpeg: truncated segment length: %w", err)
+		}
+		payload := make([]byte, int(length)-2)
+		if _, err := r.Read(payload); err != nil {
+			return 0, 0, nil, fmt.Errorf("jpeg: truncated segment: %w", err)
+		}
+
+		switch marker {
+		case markerSOF0:
+			if len(payload) < 5 {
+				return 0, 0, nil, fmt.Errorf("jpeg: malformed SOF0")
+			}
+			height = int(binary.BigEndian.Uint16(payload[1:3]))
+			width = int(binary.BigEndian.Uint16(payload[3:5]))
+		case markerSOS:
+			scanData, err = extractScanData(r)
+			if err != nil {
+				return 0, 0, nil, fmt.Errorf("jpeg: scan data: %w", err)
+			}
+			return width, height, scanData, nil
+		}
+	}
+}
+
+// extractScanData reads raw entropy-coded bytes from r (including any
+// 0xFF/0x00 stuffing, which bitReader itself undoes) until it encounters
+// an unstuffed marker, which ends the scan.
+func extractScanData(r *bytes.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b != 0xff {
+			buf.WriteByte(b)
+			continue
+		}
+
+		b2, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b2 == 0x00 {
+			buf.WriteByte(0xff)
+			buf.WriteByte(0x00)
+			continue
+		}
+
+		return buf.Bytes(), nil
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package jpeg
+
+import (
+	"image"
+	"math"
+)
+
+// plane is a single-component image plane, padded to a whole number of
+// 8x8 blocks by replicating the edge pixels, stored as float64 samples in
+// [0,255].
+type plane struct {
+	data   []float64
+	stride int
+}
+
+func (p *plane) at(x, y int) float64 {
+	return p.data[y*p.stride+x]
+}
+
+// toYCbCr converts img to three full-resolution (4:4:4, unsubsampled)
+// Y/Cb/Cr planes padded to pw x ph, using the standard JFIF conversion
+// matrix.
+func toYCbCr(img image.Image, bounds image.Rectangle, pw, ph int) (y, cb, cr *plane) {
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	y = &plane{data: make([]float64, pw*ph), stride: pw}
+	cb = &plane{data: make([]float64, pw*ph), stride: pw}
+	cr = &plane{data: make([]float64, pw*ph), stride: pw}
+
+	for yy := 0; yy < ph; yy++ {
+		sy := yy
+		if sy >= height {
+			sy = height - 1
+		}
+		for xx := 0; xx < pw; xx++ {
+			sx := xx
+			if sx >= width {
+				sx = width - 1
+			}
+			r, g, b, _ := img.At(bounds.Min.X+sx, bounds.Min.Y+sy).RGBA()
+			rf := float64(r / 256)
+			gf := float64(g / 256)
+			bf := float64(b / 256)
+
+			yv := 0.299*rf + 0.587*gf + 0.114*bf
+			cbv := -0.168736*rf - 0.331264*gf + 0.5*bf + 128
+			crv := 0.5*rf - 0.418688*gf - 0.081312*bf + 128
+
+			idx := yy*pw + xx
+			y.data[idx] = yv
+			cb.data[idx] = cbv
+			cr.data[idx] = crv
+		}
+	}
+	return y, cb, cr
+}
+
+// fromYCbCr recovers an RGB pixel from a YCbCr triple, reversing toYCbCr's
+// conversion matrix.
+func fromYCbCr(yv, cbv, crv float64) (r, g, b uint8) {
+	cbv -= 128
+	crv -= 128
+	rf := yv + 1.402*crv
+	gf := yv - 0.344136*cbv - 0.714136*crv
+	bf := yv + 1.772*cbv
+	return clamp8(rf), clamp8(gf), clamp8(bf)
+}
+
+func clamp8(v float64) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v + 0.5)
+}
+
+// extractBlock reads the 8x8 block at (bx,by) out of p.
+func extractBlock(p *plane, bx, by int) [8][8]float64 {
+	var block [8][8]float64
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			block[v][u] = p.at(bx+u, by+v)
+		}
+	}
+	return block
+}
+
+// forwardDCT computes the 2-D DCT-II of an 8x8 block, following the
+// definition in ITU-T.81 Annex A.
+func forwardDCT(block [8][8]float64) [8][8]float64 {
+	var out [8][8]float64
+	for v := 0; v < 8; v++ {
+		cv := 1.0
+		if v == 0 {
+			cv = 1 / math.Sqrt2
+		}
+		for u := 0; u < 8; u++ {
+			cu := 1.0
+			if u == 0 {
+				cu = 1 / math.Sqrt2
+			}
+
+			var sum float64
+			for y := 0; y < 8; y++ {
+				for x := 0; x < 8; x++ {
+					sum += block[y][x] *
+						math.Cos((2*float64(x)+1)*float64(u)*math.Pi/16) *
+						math.Cos((2*float64(y)+1)*float64(v)*math.Pi/16)
+				}
+			}
+			out[v][u] = 0.25 * cu * cv * sum
+		}
+	}
+	return out
+}
+
+// quantizeZigzag level-shifts and forward-transforms block, quantizes it
+// against quant (listed in row-major order) and returns the result
+// reordered into the zigzag scan sequence entropy coding expects.
+func quantizeZigzag(block [8][8]float64, quant [64]int) [64]int32 {
+	var shifted [8][8]float64
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			shifted[v][u] = block[v][u] - 128
+		}
+	}
+
+	dct := forwardDCT(shifted)
+
+	var natural [64]int32
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			q := quant[v*8+u]
+			natural[v*8+u] = int32(math.Round(dct[v][u] / float64(q)))
+		}
+	}
+
+	var zz [64]int32
+	for i, n := range zigzagOrder {
+		zz[i] = natural[n]
+	}
+	return zz
+}
@@ -0,0 +1,285 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package jpeg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// huffEncTable is a canonical Huffman table built from the JPEG BITS/VALUES
+// representation (ITU-T.81 Annex C), used to look up the code and bit
+// length to write for a given symbol.
+type huffEncTable struct {
+	code map[byte]uint16
+	size map[byte]uint8
+}
+
+// huffDecTable is the same canonical table, indexed the other way: from a
+// (length, code) pair back to the symbol it represents.
+type huffDecTable struct {
+	sym map[uint32]byte
+}
+
+// buildHuffman generates the per-symbol codes for a Huffman table from its
+// BITS (code-length counts) and VALUES (symbols in code order), following
+// the generate_size_table/generate_code_table procedure of Annex C.2.
+func buildHuffman(bits [16]byte, values []byte) (*huffEncTable, *huffDecTable) {
+	var sizes []uint8
+	for length := 1; length <= 16; length++ {
+		for i := 0; i < int(bits[length-1]); i++ {
+			sizes = append(sizes, uint8(length))
+		}
+	}
+
+	codes := make([]uint16, len(sizes))
+	code := uint16(0)
+	size := sizes[0]
+	for i := 0; i < len(sizes); {
+		for i < len(sizes) && sizes[i] == size {
+			codes[i] = code
+			code++
+			i++
+		}
+		code <<= 1
+		size++
+	}
+
+	enc := &huffEncTable{code: make(map[byte]uint16, len(values)), size: make(map[byte]uint8, len(values))}
+	dec := &huffDecTable{sym: make(map[uint32]byte, len(values))}
+	for i, v := range values {
+		enc.code[v] = codes[i]
+		enc.size[v] = sizes[i]
+		dec.sym[uint32(sizes[i])<<16|uint32(codes[i])] = v
+	}
+	return enc, dec
+}
+
+// categoryOf returns the JPEG magnitude category of v: the number of bits
+// needed to represent abs(v), or 0 for v == 0.
+func categoryOf(v int32) uint8 {
+	if v < 0 {
+		v = -v
+	}
+	var c uint8
+	for v > 0 {
+		c++
+		v >>= 1
+	}
+	return c
+}
+
+// categoryBits returns the cat-bit representation of v used alongside its
+// category symbol: v itself when positive, or v's one's-complement-style
+// encoding when negative, per the JPEG "EXTEND" procedure (Annex F.1.2.1).
+func categoryBits(v int32, cat uint8) uint16 {
+	if cat == 0 {
+		return 0
+	}
+	if v < 0 {
+		v += (1 << cat) - 1
+	}
+	return uint16(v) & (1<<cat - 1)
+}
+
+// categoryValue reverses categoryBits given the category and its bits.
+func categoryValue(bits uint16, cat uint8) int32 {
+	if cat == 0 {
+		return 0
+	}
+	v := int32(bits)
+	half := int32(1) << (cat - 1)
+	if v < half {
+		v -= 1<<cat - 1
+	}
+	return v
+}
+
+// bitWriter accumulates bits MSB-first into a byte stream, inserting the
+// 0x00 stuff byte JPEG requires after every literal 0xFF in entropy-coded
+// data.
+type bitWriter struct {
+	w     *bytes.Buffer
+	acc   uint32
+	nbits uint
+}
+
+func newBitWriter(w *bytes.Buffer) *bitWriter {
+	return &bitWriter{w: w}
+}
+
+func (bw *bitWriter) writeBits(code uint16, size uint8) {
+	if size == 0 {
+		return
+	}
+	bw.acc = (bw.acc << size) | uint32(code)&(1<<size-1)
+	bw.nbits += uint(size)
+	for bw.nbits >= 8 {
+		b := byte(bw.acc >> (bw.nbits - 8))
+		bw.w.WriteByte(b)
+		if b == 0xff {
+			bw.w.WriteByte(0x00)
+		}
+		bw.nbits -= 8
+	}
+}
+
+// flush pads the final partial byte with 1 bits, the conventional JPEG
+// end-of-scan padding, and writes it out.
+func (bw *bitWriter) flush() {
+	if bw.nbits == 0 {
+		return
+	}
+	pad := 8 - bw.nbits
+	b := byte((bw.acc<<pad)&0xff | (1<<pad - 1))
+	bw.w.WriteByte(b)
+	if b == 0xff {
+		bw.w.WriteByte(0x00)
+	}
+	bw.acc, bw.nbits = 0, 0
+}
+
+// bitReader is the inverse of bitWriter: it reads entropy-coded scan data
+// bit by bit, transparently undoing 0xFF/0x00 byte stuffing.
+type bitReader struct {
+	r     *bytes.Reader
+	acc   uint32
+	nbits uint
+}
+
+func newBitReader(data []byte) *bitReader {
+	return &bitReader{r: bytes.NewReader(data)}
+}
+
+func (br *bitReader) readBit() (uint16, error) {
+	if br.nbits == 0 {
+		b, err := br.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b == 0xff {
+			b2, err := br.r.ReadByte()
+			if err != nil {
+				return 0, err
+			}
+			if b2 != 0x00 {
+				return 0, io.EOF
+			}
+		}
+		br.acc = uint32(b)
+		br.nbits = 8
+	}
+	br.nbits--
+	return uint16(br.acc>>br.nbits) & 1, nil
+}
+
+func (br *bitReader) readBits(n uint8) (uint16, error) {
+	var v uint16
+	for i := uint8(0); i < n; i++ {
+		bit, err := br.readBit()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<1 | bit
+	}
+	return v, nil
+}
+
+func (dt *huffDecTable) decode(br *bitReader) (byte, error) {
+	var code uint32
+	for length := uint32(1); length <= 16; length++ {
+		bit, err := br.readBit()
+		if err != nil {
+			return 0, err
+		}
+		code = code<<1 | uint32(bit)
+		if v, ok := dt.sym[length<<16|code]; ok {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("jpeg: invalid huffman code in scan data")
+}
+
+// encodeBlock entropy-codes a single zigzag-ordered quantized block,
+// writing its DC coefficient as a diff against prevDC, and returns the
+// block's (undiffed) DC coefficient for the next call.
+func encodeBlock(bw *bitWriter, dcTable, acTable *huffEncTable, zz [64]int32, prevDC int32) int32 {
+	diff := zz[0] - prevDC
+	cat := categoryOf(diff)
+	bw.writeBits(dcTable.code[cat], dcTable.size[cat])
+	if cat > 0 {
+		bw.writeBits(categoryBits(diff, cat), cat)
+	}
+
+	run := 0
+	for k := 1; k < 64; k++ {
+		v := zz[k]
+		if v == 0 {
+			run++
+			continue
+		}
+		for run > 15 {
+			bw.writeBits(acTable.code[0xf0], acTable.size[0xf0]) // ZRL
+			run -= 16
+		}
+		cat := categoryOf(v)
+		sym := byte(run<<4) | cat
+		bw.writeBits(acTable.code[sym], acTable.size[sym])
+		bw.writeBits(categoryBits(v, cat), cat)
+		run = 0
+	}
+	if run > 0 {
+		bw.writeBits(acTable.code[0x00], acTable.size[0x00]) // EOB
+	}
+
+	return zz[0]
+}
+
+// decodeBlock reverses encodeBlock, reconstructing the zigzag-ordered
+// quantized coefficients exactly as encodeBlock wrote them (entropy coding
+// is lossless, so no requantization is involved), and returns the block's
+// DC coefficient for the next call.
+func decodeBlock(br *bitReader, dcTable, acTable *huffDecTable, prevDC int32) ([64]int32, int32, error) {
+	var zz [64]int32
+
+	cat, err := dcTable.decode(br)
+	if err != nil {
+		return zz, 0, err
+	}
+	bits, err := br.readBits(cat)
+	if err != nil {
+		return zz, 0, err
+	}
+	dc := prevDC + categoryValue(bits, cat)
+	zz[0] = dc
+
+	for k := 1; k < 64; {
+		rs, err := acTable.decode(br)
+		if err != nil {
+			return zz, 0, err
+		}
+		run := int(rs >> 4)
+		size := rs & 0x0f
+		if size == 0 {
+			if run == 15 {
+				k += 16
+				continue
+			}
+			break // EOB
+		}
+		k += run
+		if k >= 64 {
+			break
+		}
+		bits, err := br.readBits(size)
+		if err != nil {
+			return zz, 0, err
+		}
+		zz[k] = categoryValue(bits, size)
+		k++
+	}
+
+	return zz, dc, nil
+}
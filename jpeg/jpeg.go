@@ -0,0 +1,322 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+// Package jpeg provides a steganography implementation that outputs JPEG
+// image steganograms. It accepts both JPEG and PNG images as input.
+//
+// Unlike the png package, which conceals data in pixel LSBs, this package
+// conceals data in the least significant bit of the non-DC quantized luma
+// DCT coefficients of magnitude 2 or greater it produces while re-encoding the cover
+// image as a JPEG (a JSteg/F5-style embedding). This survives the image
+// remaining a normal, standard-conforming JPEG, at the cost of a capacity
+// that depends on the cover image's content rather than its dimensions
+// alone.
+package jpeg
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"math/big"
+
+	"github.com/zanicar/stegano"
+)
+
+var (
+	_ stegano.Stegano = &SteganoJPEG{}
+)
+
+// CalculateCapacity returns an upper bound on the number of payload bytes
+// (including the length header) that can be concealed within a JPEG
+// steganogram of the given pixel dimensions: one bit per non-DC
+// coefficient position across all 8x8 luma blocks. Actual usable capacity
+// is always lower, since only non-zero AC coefficients of magnitude 2 or
+// greater carry a bit; use Conceal's ErrCapacityOverflow to detect an
+// image whose content does not have enough of them.
+func CalculateCapacity(width, height int) int {
+	bw := (width + 7) / 8
+	bh := (height + 7) / 8
+	return bw * bh * 63 / 8
+}
+
+// SteganoJPEG implements the Stegano interface for JPEG image steganograms.
+type SteganoJPEG struct {
+	hcoder *stegano.HeaderCoder
+}
+
+// New returns a pointer to a new instance of SteganoJPEG that is ready to use.
+func New() *SteganoJPEG {
+	return &SteganoJPEG{hcoder: stegano.NewHeaderCoder()}
+}
+
+// secureIntn returns a cryptographically random integer in [0,n), used to
+// pick the length header's index byte.
+func secureIntn(n int) (int, error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("jpeg: secureIntn: non-positive bound %v", n)
+	}
+	v, err := crand.Int(crand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
+
+// usable reports whether a quantized AC coefficient is large enough to
+// carry a bit in its LSB: flipping the LSB of a magnitude of 2 or more
+// can never turn it into a zero coefficient, which is what lets Reveal
+// apply the same "skip zero" rule to the modified value it reads back
+// without losing sync with Conceal.
+func usable(v int32) bool {
+	if v < 0 {
+		v = -v
+	}
+	return v >= 2
+}
+
+// setLSB returns v with the least significant bit of its magnitude set to
+// bit, preserving v's sign.
+func setLSB(v int32, bit byte) int32 {
+	mag := v
+	neg := mag < 0
+	if neg {
+		mag = -mag
+	}
+	if bit == 1 {
+		mag |= 1
+	} else {
+		mag &^= 1
+	}
+	if neg {
+		return -mag
+	}
+	return mag
+}
+
+// lsb returns the least significant bit of v's magnitude.
+func lsb(v int32) byte {
+	if v < 0 {
+		v = -v
+	}
+	return byte(v & 1)
+}
+
+// Conceal decodes the image read from reader, re-encodes it as a baseline
+// JPEG and embeds data into the least significant bit of the non-DC
+// quantized luma DCT coefficients of magnitude 2 or greater (see usable)
+// of each 8x8 block, visited in
+// raster-scan block order. The resulting steganogram is written to
+// writer. The function returns an error on failure.
+//
+// Conceal uses a different slot layout than ConcealStream (a length header
+// always precedes the payload, since len(data) is already known), so
+// steganograms it produces are only revealed by Reveal, not RevealStream,
+// and vice versa.
+func (s SteganoJPEG) Conceal(data []byte, r io.Reader, w io.Writer) error {
+	log.Print("Conceal")
+	srcImg, _, err := image.Decode(r)
+	if err != nil {
+		return fmt.Errorf("image decode: %w", err)
+	}
+
+	bounds := srcImg.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+	pw := (width + 7) / 8 * 8
+	ph := (height + 7) / 8 * 8
+
+	yPlane, cbPlane, crPlane := toYCbCr(srcImg, bounds, pw, ph)
+
+	lumaQuant := scaleQuantTable(baseLumaQuant, embedQuality)
+	chromaQuant := scaleQuantTable(baseChromaQuant, embedQuality)
+
+	hdata, err := s.hcoder.HeaderBytes(len(data), secureIntn)
+	if err != nil {
+		return err
+	}
+	payload := append(hdata, data...)
+	totalBits := len(payload) * 8
+
+	// pass 1: DCT and quantize every luma block up front, so the
+	// payload's fit can be checked before any embedding happens
+	bw8 := pw / 8
+	bh8 := ph / 8
+	yBlocks := make([][64]int32, bw8*bh8)
+	usableSlots := 0
+	for by := 0; by < bh8; by++ {
+		for bx := 0; bx < bw8; bx++ {
+			zz := quantizeZigzag(extractBlock(yPlane, bx*8, by*8), lumaQuant)
+			yBlocks[by*bw8+bx] = zz
+			for k := 1; k < 64; k++ {
+				if usable(zz[k]) {
+					usableSlots++
+				}
+			}
+		}
+	}
+
+	if totalBits > usableSlots {
+		return fmt.Errorf("%w: length (%v) capacity (%v)", stegano.ErrCapacityOverflow, len(payload), usableSlots/8)
+	}
+
+	// pass 2: embed the payload's bits into the earliest qualifying luma
+	// AC coefficients, visited in raster block order
+	bitIdx := 0
+	for i := range yBlocks {
+		zz := &yBlocks[i]
+		for k := 1; k < 64 && bitIdx < totalBits; k++ {
+			if usable(zz[k]) {
+				bit := (payload[bitIdx/8] >> uint(bitIdx%8)) & 1
+				zz[k] = setLSB(zz[k], bit)
+				bitIdx++
+			}
+		}
+	}
+
+	// pass 3: entropy-code every block, in MCU (Y, Cb, Cr) raster order
+	scan := encodeScan(yBlocks, cbPlane, crPlane, chromaQuant, bw8, bh8)
+
+	if _, err := w.Write(writeJPEG(width, height, scan)); err != nil {
+		return err
+	}
+
+	log.Printf("%d bytes of data concealed", len(data))
+	return nil
+}
+
+// encodeScan entropy-codes the already DCT/quantized luma blocks together
+// with freshly DCT/quantized chroma blocks, interleaved per MCU as a
+// baseline JPEG scan requires, and returns the resulting byte-stuffed scan
+// data.
+func encodeScan(yBlocks [][64]int32, cbPlane, crPlane *plane, chromaQuant [64]int, bw8, bh8 int) []byte {
+	var scan bytes.Buffer
+	bwr := newBitWriter(&scan)
+
+	lumaDCEnc, _ := buildHuffman(lumaDCBits, lumaDCVals)
+	lumaACEnc, _ := buildHuffman(lumaACBits, lumaACVals)
+	chromaDCEnc, _ := buildHuffman(chromaDCBits, chromaDCVals)
+	chromaACEnc, _ := buildHuffman(chromaACBits, chromaACVals)
+
+	var prevY, prevCb, prevCr int32
+	for by := 0; by < bh8; by++ {
+		for bx := 0; bx < bw8; bx++ {
+			prevY = encodeBlock(bwr, lumaDCEnc, lumaACEnc, yBlocks[by*bw8+bx], prevY)
+
+			cbZZ := quantizeZigzag(extractBlock(cbPlane, bx*8, by*8), chromaQuant)
+			prevCb = encodeBlock(bwr, chromaDCEnc, chromaACEnc, cbZZ, prevCb)
+
+			crZZ := quantizeZigzag(extractBlock(crPlane, bx*8, by*8), chromaQuant)
+			prevCr = encodeBlock(bwr, chromaDCEnc, chromaACEnc, crZZ, prevCr)
+		}
+	}
+	bwr.flush()
+
+	return scan.Bytes()
+}
+
+// Reveal uncovers data concealed by Conceal. It entropy-decodes the JPEG
+// read from reader back into its exact quantized coefficients (entropy
+// coding is lossless, so this recovers them without re-deriving the
+// image's pixels), then reads the LSBs of the same non-DC luma
+// coefficients of magnitude 2 or greater Conceal used, in the same order, to recover first the
+// length header and then the payload it describes. The function returns
+// an error on failure. Reveal buffers the whole payload before returning
+// it; for a steganogram concealed with ConcealStream, use RevealStream
+// instead to write it out as it is decoded.
+func (s SteganoJPEG) Reveal(r io.Reader, w io.Writer) error {
+	log.Print("Reveal")
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+
+	width, height, scanData, err := parseJPEG(raw)
+	if err != nil {
+		return err
+	}
+
+	bw8 := (width + 7) / 8
+	bh8 := (height + 7) / 8
+
+	_, lumaDCDec := buildHuffman(lumaDCBits, lumaDCVals)
+	_, lumaACDec := buildHuffman(lumaACBits, lumaACVals)
+	_, chromaDCDec := buildHuffman(chromaDCBits, chromaDCVals)
+	_, chromaACDec := buildHuffman(chromaACBits, chromaACVals)
+
+	br := newBitReader(scanData)
+
+	var collected []byte
+	nbits := 0
+	pushBit := func(bit byte) {
+		bi := nbits % 8
+		if bi == 0 {
+			collected = append(collected, 0)
+		}
+		collected[len(collected)-1] |= bit << uint(bi)
+		nbits++
+	}
+
+	nLenBytes := -1
+	clen := -1
+	var prevY, prevCb, prevCr int32
+
+done:
+	for by := 0; by < bh8; by++ {
+		for bx := 0; bx < bw8; bx++ {
+			yZZ, dc, err := decodeBlock(br, lumaDCDec, lumaACDec, prevY)
+			if err != nil {
+				return fmt.Errorf("decode luma block: %w", err)
+			}
+			prevY = dc
+
+			for k := 1; k < 64; k++ {
+				if !usable(yZZ[k]) {
+					continue
+				}
+				pushBit(lsb(yZZ[k]))
+
+				if nLenBytes == -1 && nbits == 8 {
+					nLenBytes = int(s.hcoder.ByteCountFor(collected[0])) + 1
+				}
+				if clen == -1 && nLenBytes != -1 && nbits == (1+nLenBytes)*8 {
+					clen = 0
+					for i, cb := range collected[1 : 1+nLenBytes] {
+						clen |= int(cb) << uint(i*8)
+					}
+				}
+				if clen != -1 && nbits == (1+nLenBytes+clen)*8 {
+					break done
+				}
+			}
+
+			_, dcb, err := decodeBlock(br, chromaDCDec, chromaACDec, prevCb)
+			if err != nil {
+				return fmt.Errorf("decode chroma blue block: %w", err)
+			}
+			prevCb = dcb
+
+			_, dcr, err := decodeBlock(br, chromaDCDec, chromaACDec, prevCr)
+			if err != nil {
+				return fmt.Errorf("decode chroma red block: %w", err)
+			}
+			prevCr = dcr
+		}
+	}
+
+	if clen == -1 || nbits < (1+nLenBytes+clen)*8 {
+		return fmt.Errorf("stegano: no concealed data found")
+	}
+
+	n, err := w.Write(collected[1+nLenBytes:])
+	if err != nil {
+		return err
+	}
+
+	log.Printf("%d bytes of data revealed", n)
+	return nil
+}
@@ -0,0 +1,28 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package jpeg
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestConcealReveal(t *testing.T) {
+	cover := makeCoverJPEG(t, 128, 128)
+	data := []byte("keyless conceal/reveal must roundtrip through jpeg")
+
+	s := New()
+	var out bytes.Buffer
+	if err := s.Conceal(data, bytes.NewReader(cover), &out); err != nil {
+		t.Fatalf("Conceal: %v", err)
+	}
+
+	var revealed bytes.Buffer
+	if err := s.Reveal(bytes.NewReader(out.Bytes()), &revealed); err != nil {
+		t.Fatalf("Reveal: %v", err)
+	}
+	if revealed.String() != string(data) {
+		t.Fatalf("roundtrip mismatch: got %q want %q", revealed.String(), string(data))
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package jpeg
+
+import (
+	crand "crypto/rand"
+	"fmt"
+	"image"
+	"io"
+	"log"
+
+	"github.com/zanicar/stegano"
+)
+
+var (
+	_ stegano.StreamStegano = &SteganoJPEG{}
+)
+
+// ConcealStream conceals up to size bytes read from src into the non-DC
+// luma DCT coefficients of magnitude 2 or greater (see usable) of the
+// image read from cover, in the same
+// order Conceal visits them, and writes the resulting JPEG steganogram to
+// out. If size is -1, the payload's length is not known up front; a
+// terminator-sentinel marker (see stegano.StreamTerminator) is embedded
+// after the payload instead of a length header.
+//
+// Unlike Conceal, which requires the whole payload to compute how much of
+// it fits ahead of embedding, ConcealStream pulls payload bytes from src
+// lazily, one at a time, as it embeds them, so its memory use does not
+// grow with the payload's size.
+func (s SteganoJPEG) ConcealStream(src, cover io.Reader, out io.Writer, size int64) error {
+	log.Print("ConcealStream")
+
+	srcImg, _, err := image.Decode(cover)
+	if err != nil {
+		return fmt.Errorf("image decode: %w", err)
+	}
+
+	bounds := srcImg.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+	pw := (width + 7) / 8 * 8
+	ph := (height + 7) / 8 * 8
+
+	yPlane, cbPlane, crPlane := toYCbCr(srcImg, bounds, pw, ph)
+
+	lumaQuant := scaleQuantTable(baseLumaQuant, embedQuality)
+	chromaQuant := scaleQuantTable(baseChromaQuant, embedQuality)
+
+	prefix := make([]byte, stegano.StreamPrefixLen)
+	var hdata []byte
+	var source *stegano.PayloadSource
+	if size < 0 {
+		nonce := make([]byte, stegano.TerminatorSize)
+		if _, err := crand.Read(nonce); err != nil {
+			return fmt.Errorf("terminator nonce: %w", err)
+		}
+		prefix[0] = stegano.StreamFlagTerminated
+		copy(prefix[1:], nonce)
+		source = stegano.NewTerminatedPayloadSource(src, stegano.StreamTerminator(nonce))
+	} else {
+		hdata, err = s.hcoder.HeaderBytes(int(size), secureIntn)
+		if err != nil {
+			return err
+		}
+		source = stegano.NewSizedPayloadSource(src, size)
+	}
+
+	prefixBits := len(prefix) * 8
+	headerBits := len(hdata) * 8
+
+	bw8 := pw / 8
+	bh8 := ph / 8
+	yBlocks := make([][64]int32, bw8*bh8)
+	for by := 0; by < bh8; by++ {
+		for bx := 0; bx < bw8; bx++ {
+			yBlocks[by*bw8+bx] = quantizeZigzag(extractBlock(yPlane, bx*8, by*8), lumaQuant)
+		}
+	}
+
+	bitIdx := 0
+	lastByteIdx := -1
+	var lastVal byte
+	finished := false
+	for i := range yBlocks {
+		if finished {
+			break
+		}
+		zz := &yBlocks[i]
+		for k := 1; k < 64; k++ {
+			if finished {
+				break
+			}
+			if !usable(zz[k]) {
+				continue
+			}
+
+			var bit byte
+			switch {
+			case bitIdx < prefixBits:
+				bit = (prefix[bitIdx/8] >> uint(bitIdx%8)) & 1
+			case bitIdx < prefixBits+headerBits:
+				hbi := bitIdx - prefixBits
+				bit = (hdata[hbi/8] >> uint(hbi%8)) & 1
+			default:
+				pbi := bitIdx - prefixBits - headerBits
+				byteIdx := pbi / 8
+				if byteIdx != lastByteIdx {
+					val, ok, err := source.Next()
+					if err != nil {
+						return err
+					}
+					if !ok {
+						finished = true
+						continue
+					}
+					lastVal = val
+					lastByteIdx = byteIdx
+				}
+				bit = (lastVal >> uint(pbi%8)) & 1
+			}
+
+			zz[k] = setLSB(zz[k], bit)
+			bitIdx++
+		}
+	}
+
+	if !finished {
+		return fmt.Errorf("%w: streaming payload did not fit in cover capacity", stegano.ErrCapacityOverflow)
+	}
+
+	scan := encodeScan(yBlocks, cbPlane, crPlane, chromaQuant, bw8, bh8)
+
+	if _, err := out.Write(writeJPEG(width, height, scan)); err != nil {
+		return err
+	}
+
+	log.Print("streaming data concealed")
+	return nil
+}
+
+// RevealStream uncovers data concealed by ConcealStream from the JPEG read
+// from cover and writes it to dst as it is decoded, rather than buffering
+// the whole payload first.
+func (s SteganoJPEG) RevealStream(cover io.Reader, dst io.Writer) error {
+	log.Print("RevealStream")
+
+	raw, err := io.ReadAll(cover)
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+
+	width, height, scanData, err := parseJPEG(raw)
+	if err != nil {
+		return err
+	}
+
+	bw8 := (width + 7) / 8
+	bh8 := (height + 7) / 8
+
+	_, lumaDCDec := buildHuffman(lumaDCBits, lumaDCVals)
+	_, lumaACDec := buildHuffman(lumaACBits, lumaACVals)
+	_, chromaDCDec := buildHuffman(chromaDCBits, chromaDCVals)
+	_, chromaACDec := buildHuffman(chromaACBits, chromaACVals)
+
+	br := newBitReader(scanData)
+
+	prefix := make([]byte, stegano.StreamPrefixLen)
+	var hdata []byte
+	var sink *stegano.PayloadSink
+	headerBits := -1
+	clen := 0
+
+	var dbyte byte
+	nbits := 0
+	finished := false
+	var prevY, prevCb, prevCr int32
+
+done:
+	for by := 0; by < bh8; by++ {
+		for bx := 0; bx < bw8; bx++ {
+			yZZ, dc, err := decodeBlock(br, lumaDCDec, lumaACDec, prevY)
+			if err != nil {
+				return fmt.Errorf("decode luma block: %w", err)
+			}
+			prevY = dc
+
+			for k := 1; k < 64; k++ {
+				if !usable(yZZ[k]) {
+					continue
+				}
+
+				bi := nbits % 8
+				if bi == 0 {
+					dbyte = 0
+				}
+				dbyte |= lsb(yZZ[k]) << uint(bi)
+				nbits++
+
+				switch {
+				case nbits <= len(prefix)*8:
+					if bi == 7 {
+						prefix[nbits/8-1] = dbyte
+						if nbits == len(prefix)*8 && prefix[0]&stegano.StreamFlagTerminated != 0 {
+							headerBits = 0
+							sink = stegano.NewTerminatedPayloadSink(dst, stegano.StreamTerminator(prefix[1:]))
+						}
+					}
+				case headerBits < 0 || nbits <= len(prefix)*8+headerBits:
+					if bi == 7 {
+						if hdata == nil {
+							hdata = make([]byte, s.hcoder.ByteCountFor(dbyte)+1+1)
+						}
+						hbi := nbits/8 - 1 - len(prefix)
+						hdata[hbi] = dbyte
+						if hbi == len(hdata)-1 {
+							cld := hdata[1:]
+							for i := 0; i < len(cld); i++ {
+								for ii := 0; ii < 8; ii++ {
+									tbit := cld[i] & (1 << uint(ii))
+									clen |= int(uint(tbit) << uint(i*8))
+								}
+							}
+							headerBits = len(hdata) * 8
+							if clen == 0 {
+								finished = true
+								break done
+							}
+							sink = stegano.NewSizedPayloadSink(dst, int64(clen))
+						}
+					}
+				default:
+					if bi == 7 && sink != nil {
+						sdone, err := sink.Push(dbyte)
+						if err != nil {
+							return err
+						}
+						if sdone {
+							finished = true
+							break done
+						}
+					}
+				}
+			}
+
+			_, dcb, err := decodeBlock(br, chromaDCDec, chromaACDec, prevCb)
+			if err != nil {
+				return fmt.Errorf("decode chroma blue block: %w", err)
+			}
+			prevCb = dcb
+
+			_, dcr, err := decodeBlock(br, chromaDCDec, chromaACDec, prevCr)
+			if err != nil {
+				return fmt.Errorf("decode chroma red block: %w", err)
+			}
+			prevCr = dcr
+		}
+	}
+
+	if !finished {
+		return fmt.Errorf("stegano: no concealed data found")
+	}
+
+	log.Print("streaming data revealed")
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package jpeg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	stdjpeg "image/jpeg"
+	"testing"
+)
+
+func makeCoverJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 3), G: uint8(y * 5), B: uint8((x * y) % 251), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := stdjpeg.Encode(&buf, img, &stdjpeg.Options{Quality: 95}); err != nil {
+		t.Fatalf("encode cover: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestConcealStreamRevealStreamSized(t *testing.T) {
+	cover := makeCoverJPEG(t, 128, 128)
+	data := []byte("sized streaming payload must roundtrip through jpeg")
+
+	s := New()
+	var out bytes.Buffer
+	if err := s.ConcealStream(bytes.NewReader(data), bytes.NewReader(cover), &out, int64(len(data))); err != nil {
+		t.Fatalf("ConcealStream: %v", err)
+	}
+
+	var revealed bytes.Buffer
+	if err := s.RevealStream(bytes.NewReader(out.Bytes()), &revealed); err != nil {
+		t.Fatalf("RevealStream: %v", err)
+	}
+	if revealed.String() != string(data) {
+		t.Fatalf("roundtrip mismatch: got %q want %q", revealed.String(), string(data))
+	}
+}
+
+func TestConcealStreamRevealStreamUnsized(t *testing.T) {
+	cover := makeCoverJPEG(t, 128, 128)
+	data := []byte("unsized streaming payload, terminated rather than length-prefixed")
+
+	s := New()
+	var out bytes.Buffer
+	if err := s.ConcealStream(bytes.NewReader(data), bytes.NewReader(cover), &out, -1); err != nil {
+		t.Fatalf("ConcealStream: %v", err)
+	}
+
+	var revealed bytes.Buffer
+	if err := s.RevealStream(bytes.NewReader(out.Bytes()), &revealed); err != nil {
+		t.Fatalf("RevealStream: %v", err)
+	}
+	if revealed.String() != string(data) {
+		t.Fatalf("roundtrip mismatch: got %q want %q", revealed.String(), string(data))
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package stegano
+
+import (
+	"crypto/cipher"
+	crand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20"
+)
+
+// NonceSize is the size, in bytes, of the nonce used to seed a keyed
+// permutation. Implementations store it unencrypted at a fixed, known
+// offset so Reveal can recover it - and so derive the same permutation -
+// before the permutation itself is known.
+const NonceSize = chacha20.NonceSize
+
+// NewNonce returns a cryptographically random nonce suitable for seeding a
+// keyed permutation.
+func NewNonce() ([]byte, error) {
+	nonce := make([]byte, NonceSize)
+	if _, err := crand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("stegano: nonce: %w", err)
+	}
+	return nonce, nil
+}
+
+// Permutation derives a pseudo-random permutation of the integers [0,n)
+// from a 32-byte key and a NonceSize-byte nonce, using ChaCha20 as a keyed
+// CSPRNG to drive a Fisher-Yates shuffle. The same key and nonce always
+// yield the same permutation, which is what lets Reveal invert it; without
+// the key, the resulting order is indistinguishable from independent noise.
+func Permutation(key, nonce []byte, n int) ([]int, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("stegano: permutation key must be 32 bytes, got %v", len(key))
+	}
+	if len(nonce) != NonceSize {
+		return nil, fmt.Errorf("stegano: permutation nonce must be %v bytes, got %v", NonceSize, len(nonce))
+	}
+
+	stream, err := chacha20.NewUnauthenticatedCipher(key, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("stegano: permutation: %w", err)
+	}
+
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	for i := n - 1; i > 0; i-- {
+		j, err := keystreamIntn(stream, i+1)
+		if err != nil {
+			return nil, err
+		}
+		perm[i], perm[j] = perm[j], perm[i]
+	}
+
+	return perm, nil
+}
+
+// InvertPermutation returns the inverse of perm, i.e. a slice inv such that
+// inv[perm[i]] == i for all i.
+func InvertPermutation(perm []int) []int {
+	inv := make([]int, len(perm))
+	for i, p := range perm {
+		inv[p] = i
+	}
+	return inv
+}
+
+// keystreamIntn draws a uniform random integer in [0,n) from stream via
+// rejection sampling, avoiding the modulo bias a plain "% n" would
+// introduce.
+func keystreamIntn(stream cipher.Stream, n int) (int, error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("stegano: keystreamIntn: non-positive bound %v", n)
+	}
+
+	bound := uint64(n)
+	limit := (^uint64(0)) - (^uint64(0))%bound
+
+	buf := make([]byte, 8)
+	zero := make([]byte, 8)
+	for {
+		stream.XORKeyStream(buf, zero)
+		v := binary.BigEndian.Uint64(buf)
+		if v < limit {
+			return int(v % bound), nil
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package png
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/zanicar/stegano"
+	"github.com/zanicar/stegano/rs"
+)
+
+const (
+	// fecDataShards is the number of payload bytes RS-encoded together as a
+	// single block. 128 bytes keeps blocks small enough that a cluster of
+	// corrupted pixels (a crop, a re-touch, a lossy round-trip) only ever
+	// takes out a handful of blocks rather than the whole payload.
+	fecDataShards = 128
+
+	// modeDataShards/modeTotalShards protect the tiny mode header (FEC flag,
+	// parity count and original payload length) with a much heavier code,
+	// since losing it makes the rest of the image unrecoverable regardless
+	// of how well the payload itself survived.
+	modeDataShards  = 5
+	modeTotalShards = 15
+
+	// modeHeaderRawLen is the number of raw bytes carried by the mode
+	// header: 1 FEC flag byte, 1 parity-shard-count byte and 4 bytes of
+	// original (pre-FEC) payload length, padded to a whole number of
+	// modeDataShards-sized blocks.
+	modeHeaderRawLen = 10
+)
+
+var modeFEC = mustFEC(modeDataShards, modeTotalShards-modeDataShards)
+
+// fecConfig wraps an rs.RS codec with the fixed-size block splitting needed
+// to RS-protect an arbitrary length byte slice.
+type fecConfig struct {
+	rs *rs.RS
+}
+
+func mustFEC(dataShards, parityShards int) *fecConfig {
+	codec, err := rs.New(dataShards, parityShards)
+	if err != nil {
+		panic(err)
+	}
+	return &fecConfig{rs: codec}
+}
+
+// newPayloadFEC builds the payload FEC codec for the given parity shard
+// count, with a fixed fecDataShards-byte block size.
+func newPayloadFEC(parityShards int) (*fecConfig, error) {
+	codec, err := rs.New(fecDataShards, parityShards)
+	if err != nil {
+		return nil, fmt.Errorf("fec: %w", err)
+	}
+	return &fecConfig{rs: codec}, nil
+}
+
+// parityShardsForRate turns a tunable rate (parity bytes per data byte, e.g.
+// 8/128 = 0.0625) into a parity shard count for a fecDataShards-byte block.
+// The result is clamped to [1, 255-fecDataShards], the range rs.New always
+// accepts for a fecDataShards-byte block, so no caller-supplied rate - however
+// large - can push the RS block size past the 255-byte symbol limit.
+func parityShardsForRate(rate float64) int {
+	parity := int(float64(fecDataShards)*rate + 0.5)
+	if parity < 1 {
+		parity = 1
+	}
+	if max := 255 - fecDataShards; parity > max {
+		parity = max
+	}
+	return parity
+}
+
+// blockSize returns the total (data+parity) size of one RS block.
+func (f *fecConfig) blockSize() int { return f.rs.BlockSize() }
+
+// encode RS-encodes data in fixed size blocks, zero-padding the final block.
+func (f *fecConfig) encode(data []byte) []byte {
+	dataShards := f.rs.DataShards()
+	out := make([]byte, 0, f.encodedLen(len(data)))
+	for i := 0; i < len(data); i += dataShards {
+		end := i + dataShards
+		block := make([]byte, dataShards)
+		if end > len(data) {
+			copy(block, data[i:])
+		} else {
+			copy(block, data[i:end])
+		}
+		enc, err := f.rs.Encode(block)
+		if err != nil {
+			// blocks are always exactly dataShards bytes, so this can't fail
+			panic(err)
+		}
+		out = append(out, enc...)
+	}
+	return out
+}
+
+// decode reverses encode, correcting errors block by block, and trims the
+// result back to the original dataLen bytes.
+func (f *fecConfig) decode(data []byte, dataLen int) ([]byte, error) {
+	bs := f.blockSize()
+	out := make([]byte, 0, len(data)/bs*f.rs.DataShards())
+	for i := 0; i+bs <= len(data); i += bs {
+		block, _, err := f.rs.Decode(data[i : i+bs])
+		if err != nil {
+			return nil, fmt.Errorf("fec: block %v: %w", i/bs, err)
+		}
+		out = append(out, block...)
+	}
+	if len(out) < dataLen {
+		return nil, fmt.Errorf("fec: decoded %v bytes, want at least %v", len(out), dataLen)
+	}
+	return out[:dataLen], nil
+}
+
+// encodedLen returns the number of encoded bytes produced for dataLen bytes
+// of input.
+func (f *fecConfig) encodedLen(dataLen int) int {
+	dataShards := f.rs.DataShards()
+	blocks := dataLen / dataShards
+	if dataLen%dataShards != 0 {
+		blocks++
+	}
+	if blocks == 0 {
+		blocks = 1
+	}
+	return blocks * f.blockSize()
+}
+
+// encodeModeHeader builds and RS-protects the fixed-size mode header that
+// precedes the length header in the bitstream.
+func encodeModeHeader(fec *fecConfig, origLen int) []byte {
+	raw := make([]byte, modeHeaderRawLen)
+	if fec != nil {
+		raw[0] = 1
+		raw[1] = byte(fec.rs.ParityShards())
+	}
+	binary.BigEndian.PutUint32(raw[2:6], uint32(origLen))
+	return modeFEC.encode(raw)
+}
+
+// decodeModeHeader reverses encodeModeHeader, returning the payload FEC
+// config (nil if FEC was not used) and the original, pre-FEC payload length.
+func decodeModeHeader(encoded []byte) (fec *fecConfig, origLen int, err error) {
+	raw, err := modeFEC.decode(encoded, modeHeaderRawLen)
+	if err != nil {
+		return nil, 0, fmt.Errorf("mode header: %w", err)
+	}
+	origLen = int(binary.BigEndian.Uint32(raw[2:6]))
+	if raw[0] == 0 {
+		return nil, origLen, nil
+	}
+	fec, err = newPayloadFEC(int(raw[1]))
+	if err != nil {
+		return nil, 0, err
+	}
+	return fec, origLen, nil
+}
+
+// modeHeaderEncodedLen is the fixed number of bytes the mode header always
+// occupies in the bitstream, FEC-enabled or not.
+func modeHeaderEncodedLen() int {
+	return modeFEC.encodedLen(modeHeaderRawLen)
+}
+
+// FECCodec adapts the Reed-Solomon FEC layer to the stegano.Codec
+// interface, so it can be used as a standalone, reusable payload
+// transformation independently of png's own mode-header-based wire format
+// (which instead threads the pre-FEC length through encodeModeHeader /
+// decodeModeHeader alongside the parity rate).
+type FECCodec struct {
+	fec *fecConfig
+}
+
+var _ stegano.Codec = (*FECCodec)(nil)
+
+// NewFECCodec returns a stegano.Codec that RS-protects data at the given
+// parity rate (see WithFEC for the rate's meaning).
+func NewFECCodec(rate float64) (*FECCodec, error) {
+	fec, err := newPayloadFEC(parityShardsForRate(rate))
+	if err != nil {
+		return nil, err
+	}
+	return &FECCodec{fec: fec}, nil
+}
+
+// Encode RS-protects data, prefixed with its own length so Decode can trim
+// the final block's zero-padding without needing the length out of band.
+func (c *FECCodec) Encode(data []byte) ([]byte, error) {
+	prefixed := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(prefixed[:4], uint32(len(data)))
+	copy(prefixed[4:], data)
+	return c.fec.encode(prefixed), nil
+}
+
+// Decode reverses Encode, correcting up to the configured number of errors
+// per RS block.
+func (c *FECCodec) Decode(data []byte) ([]byte, error) {
+	bs := c.fec.blockSize()
+	if len(data) < bs {
+		return nil, fmt.Errorf("fec: codec: block too short (%v bytes, want at least %v)", len(data), bs)
+	}
+	maxLen := len(data) / bs * c.fec.rs.DataShards()
+	raw, err := c.fec.decode(data, maxLen)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < 4 {
+		return nil, fmt.Errorf("fec: codec: decoded length prefix truncated")
+	}
+	n := int(binary.BigEndian.Uint32(raw[:4]))
+	if n < 0 || 4+n > len(raw) {
+		return nil, fmt.Errorf("fec: codec: decoded length %v exceeds available %v bytes", n, len(raw)-4)
+	}
+	return raw[4 : 4+n], nil
+}
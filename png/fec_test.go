@@ -0,0 +1,65 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package png
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFECCodecRoundTrip(t *testing.T) {
+	codec, err := NewFECCodec(8.0 / 128.0)
+	if err != nil {
+		t.Fatalf("NewFECCodec: %v", err)
+	}
+	data := []byte("round trip through the stegano.Codec interface")
+
+	encoded, err := codec.Encode(data)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	decoded, err := codec.Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Fatalf("roundtrip mismatch: got %q want %q", decoded, data)
+	}
+}
+
+func TestFECCodecEmptyPayload(t *testing.T) {
+	codec, err := NewFECCodec(8.0 / 128.0)
+	if err != nil {
+		t.Fatalf("NewFECCodec: %v", err)
+	}
+
+	encoded, err := codec.Encode(nil)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	decoded, err := codec.Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(decoded) != 0 {
+		t.Fatalf("expected empty payload, got %q", decoded)
+	}
+}
+
+func TestFECCodecRejectsTruncatedBlock(t *testing.T) {
+	codec, err := NewFECCodec(8.0 / 128.0)
+	if err != nil {
+		t.Fatalf("NewFECCodec: %v", err)
+	}
+	encoded, err := codec.Encode([]byte("data"))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	if _, err := codec.Decode(encoded[:codec.fec.blockSize()-1]); err == nil {
+		t.Fatalf("expected error decoding a truncated block, got nil")
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package png
+
+import (
+	"crypto/sha256"
+
+	"github.com/zanicar/stegano"
+)
+
+// prefixLen is the size, in bytes, of the fixed, always-raster-order prefix
+// that precedes the (optionally key-permuted) header and payload: one
+// keyed-mode flag byte followed by the permutation nonce. It must stay in
+// raster order since the permutation itself cannot be derived until the
+// nonce has been read back.
+const prefixLen = 1 + stegano.NonceSize
+
+// deriveKey folds arbitrary-length key material down to the 32 bytes
+// stegano.Permutation requires.
+func deriveKey(key []byte) []byte {
+	sum := sha256.Sum256(key)
+	return sum[:]
+}
+
+// slotMapper translates the logical, key-permuted bit slots visited when
+// building or reading the header and payload onto physical (raster order)
+// encoding slots, and back. It is the identity mapping in keyless mode, so
+// existing keyless steganograms keep decoding exactly as before.
+type slotMapper struct {
+	key  []byte
+	perm []int // logical -> physical, built lazily once the nonce is known
+	inv  []int // physical -> logical
+	// size is the permuted region size (a multiple of 4); indices at or
+	// past size are a trailing remainder left out of the permutation, and
+	// pass through unchanged in raster order.
+	size int
+}
+
+func newSlotMapper(key []byte) *slotMapper {
+	return &slotMapper{key: key}
+}
+
+// build derives the permutation over the n logical slots following the
+// prefix, seeded by nonce. It is a no-op in keyless mode.
+//
+// The permutation is computed over groups of 4 consecutive slots (the
+// slots making up a single data byte) rather than individual slots, and
+// toLogical/toPhysical preserve each slot's position within its group.
+// Conceal and Reveal rebuild a data byte from 4 slots assumed to sit next
+// to each other; permuting individual slots would scatter those 4 bits
+// across unrelated bytes and make them impossible to reassemble.
+// Permuting whole groups instead keeps each byte's 4 slots together -
+// only which logical byte lands in which physical group is shuffled.
+//
+// n is not guaranteed to be a multiple of 4 (it depends on the carrier
+// image's dimensions), so the permutation only covers n's largest
+// multiple-of-4 prefix; the trailing remainder of up to 3 slots is left
+// out and handled by toLogical/toPhysical as an identity mapping, the
+// same way the fixed prefix is handled in raster order.
+func (m *slotMapper) build(nonce []byte, n int) error {
+	if m.key == nil {
+		return nil
+	}
+	size := n - n%4
+	perm, err := stegano.Permutation(m.key, nonce, size/4)
+	if err != nil {
+		return err
+	}
+	m.perm = perm
+	m.inv = stegano.InvertPermutation(perm)
+	m.size = size
+	return nil
+}
+
+// toLogical translates a physical slot index (relative to the start of the
+// permuted region) to its logical counterpart, keeping the slot's position
+// within its 4-slot group unchanged. Indices at or past the permuted
+// region's size (the trailing remainder) pass through unchanged.
+func (m *slotMapper) toLogical(physical int) int {
+	if m.inv == nil || physical >= m.size {
+		return physical
+	}
+	group, sub := physical/4, physical%4
+	return m.inv[group]*4 + sub
+}
+
+// toPhysical translates a logical slot index to its physical counterpart
+// (relative to the start of the permuted region), the inverse of
+// toLogical. Reveal uses it to fetch a logical byte's 4 slots directly,
+// without needing to have visited every slot before it.
+func (m *slotMapper) toPhysical(logical int) int {
+	if m.perm == nil || logical >= m.size {
+		return logical
+	}
+	group, sub := logical/4, logical%4
+	return m.perm[group]*4 + sub
+}
@@ -6,6 +6,7 @@
 package png
 
 import (
+	crand "crypto/rand"
 	"fmt"
 	"image"
 	"image/color"
@@ -13,9 +14,7 @@ import (
 	"image/png"
 	"io"
 	"log"
-	"math"
-	"math/rand"
-	"time"
+	"math/big"
 
 	"github.com/zanicar/stegano"
 )
@@ -25,53 +24,93 @@ var (
 )
 
 // CalculateCapacity determines the maximum number of bytes that can be
-// concealed within the image of the given parameters.
+// concealed within the image of the given parameters. This is the raw
+// carrier capacity; it does not account for the length header, or for
+// forward error correction overhead when FEC is enabled (see
+// CalculatePayloadCapacity).
 func CalculateCapacity(width, height, channels, bitsPerByte int) int {
 	return width * height * channels * bitsPerByte / 8
 }
 
+// CalculatePayloadCapacity determines the maximum number of pre-FEC payload
+// bytes that can be concealed within an image of the given parameters when
+// FEC is enabled at parityRate (parity bytes per data byte, e.g. 8/128 =
+// 0.0625). A parityRate of 0 disables the FEC overhead calculation and
+// returns the same budget CalculateCapacity minus the fixed prefix and mode
+// header would give. With FEC, only whole RS blocks are counted, since a
+// partial final block is padded to a full one. The variable length header
+// is not accounted for here, since its size in turn depends on the payload
+// length it describes.
+func CalculatePayloadCapacity(width, height, channels, bitsPerByte int, parityRate float64) int {
+	raw := CalculateCapacity(width, height, channels, bitsPerByte) - prefixLen - modeHeaderEncodedLen()
+	if parityRate <= 0 {
+		return raw
+	}
+	parityShards := parityShardsForRate(parityRate)
+	return raw / (fecDataShards + parityShards) * fecDataShards
+}
+
+// Option configures a SteganoPNG instance returned by New.
+type Option func(*SteganoPNG)
+
+// WithFEC enables Reed-Solomon forward error correction on the concealed
+// payload, at the given parity rate (parity bytes per data byte of a
+// fecDataShards-byte block, e.g. 8.0/128.0). FEC lets the hidden message
+// survive partial corruption of the carrier image - a few re-touched
+// pixels, a screenshot round-trip, a small crop, or a lossy re-encoding -
+// at the cost of reduced payload capacity. A zero or negative rate leaves
+// FEC disabled. Rates that would require more parity than a
+// fecDataShards-byte block can hold (above roughly 0.99) are silently
+// clamped to the largest rate the code supports, rather than failing.
+func WithFEC(rate float64) Option {
+	return func(s *SteganoPNG) {
+		s.fecRate = rate
+	}
+}
+
+// WithKey enables key-scheduled concealment: header and payload encoding
+// slots are visited in a key-derived pseudo-random order instead of raster
+// scan order, so that without the key the steganogram is indistinguishable
+// from independent noise. A nil or empty key leaves the keyless,
+// raster-order mode in place for backward compatibility.
+func WithKey(key []byte) Option {
+	return func(s *SteganoPNG) {
+		if len(key) > 0 {
+			s.permKey = deriveKey(key)
+		}
+	}
+}
+
 // SteganoPNG implements the Stegano interface for PNG image steganograms.
 type SteganoPNG struct {
-	hcoder [][]uint8
-	hmap   map[uint8]uint8
+	hcoder *stegano.HeaderCoder
+
+	fecRate float64
+	fec     *fecConfig
+
+	permKey []byte
 }
 
 // New returns a pointer to a new instance of SteganoPNG that is ready to use.
-func New() *SteganoPNG {
+func New(opts ...Option) *SteganoPNG {
 	spng := &SteganoPNG{}
-	spng.initHCoder()
-	return spng
-}
+	spng.hcoder = stegano.NewHeaderCoder()
+
+	for _, opt := range opts {
+		opt(spng)
+	}
 
-// dataLengthEncoder allows for the content length (specified in uint32 [4 bytes]) to be concealed
-// by dividing the range of values of a single byte (0-255) into 4 slices, each representing one,
-// two, three or four bytes. The content length can now be concealed in the target data by encoding
-// an appropriately selected random number as the first concealed data byte to denote the number of
-// bytes that represent the content length.
-func (s *SteganoPNG) initHCoder() {
-	s.hcoder = make([][]uint8, 4)
-	s.hcoder[0] = make([]uint8, 0)
-	s.hcoder[1] = make([]uint8, 0)
-	s.hcoder[2] = make([]uint8, 0)
-	s.hcoder[3] = make([]uint8, 0)
-	s.hmap = make(map[uint8]uint8)
-
-	for i := 0; i < 256; i++ {
-		switch {
-		case i%4 == 0 && i/4 > 0: // slice representing 4 bytes [4 294 967 296 -> 4GB]
-			s.hcoder[3] = append(s.hcoder[3], uint8(i))
-			s.hmap[uint8(i)] = 3
-		case i%3 == 0 && i/3 > 0: // slice representing 3 bytes [16 777 216 -> 16MB]
-			s.hcoder[2] = append(s.hcoder[2], uint8(i))
-			s.hmap[uint8(i)] = 2
-		case i%2 == 0 && i/2 > 0: // slice representing 2 bytes [65 536 -> 65KB]
-			s.hcoder[1] = append(s.hcoder[1], uint8(i))
-			s.hmap[uint8(i)] = 1
-		case i%1 == 0 && i/1 > 0: // slice representing 1 byte [255]
-			s.hcoder[0] = append(s.hcoder[0], uint8(i))
-			s.hmap[uint8(i)] = 0
+	if spng.fecRate > 0 {
+		fec, err := newPayloadFEC(parityShardsForRate(spng.fecRate))
+		if err != nil {
+			// parityShardsForRate is bounded to values rs.New always accepts
+			// for the fixed fecDataShards block size.
+			panic(err)
 		}
+		spng.fec = fec
 	}
+
+	return spng
 }
 
 // headerBytes accepts the content length and returns its byte representation as a byte slice
@@ -79,47 +118,42 @@ func (s *SteganoPNG) initHCoder() {
 // Thus the function returns a byte slice of length n + 1, where n is the minimum number of bytes
 // required to represent the uint32 content length value.
 func (s SteganoPNG) headerBytes(dlen int) ([]byte, error) {
-	max := int(math.Pow(2, 32))
-	if dlen > max {
-		return nil, fmt.Errorf("%w: length (%v) max (%v)", stegano.ErrCapacityMax, dlen, max)
-	}
-
-	bitcount := len(fmt.Sprintf("%08b", dlen))
-	bytecount := bitcount / 8
-	if bitcount%8 > 0 {
-		bytecount++
-	}
+	return s.hcoder.HeaderBytes(dlen, secureIntn)
+}
 
-	b := make([]byte, bytecount)
-	l := dlen
-	for bi := 0; bi < bytecount; bi++ {
-		b[bi] |= uint8(l & 255)
-		l = l >> 8
+// secureIntn returns a cryptographically random integer in [0,n), used to
+// pick the header's index byte so it cannot be predicted or used as a
+// statistical fingerprint.
+func secureIntn(n int) (int, error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("png: secureIntn: non-positive bound %v", n)
 	}
-
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	switch {
-	case dlen < int(math.Pow(2, 8)):
-		ri := r.Intn(len(s.hcoder[0]))
-		b = append([]byte{s.hcoder[0][ri]}, b...)
-	case dlen < int(math.Pow(2, 16)):
-		ri := r.Intn(len(s.hcoder[1]))
-		b = append([]byte{s.hcoder[1][ri]}, b...)
-	case dlen < int(math.Pow(2, 24)):
-		ri := r.Intn(len(s.hcoder[2]))
-		b = append([]byte{s.hcoder[2][ri]}, b...)
-	case dlen < int(math.Pow(2, 32)):
-		ri := r.Intn(len(s.hcoder[3]))
-		b = append([]byte{s.hcoder[3][ri]}, b...)
+	v, err := crand.Int(crand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
 	}
-
-	return b, nil
+	return int(v.Int64()), nil
 }
 
 // Conceal encodes the given data to the two least significant bits of the RGB channels of the image
 // decoded from reader and writes a new PNG image to the writer. The alpha channel is deliberately ommitted
 // as alpha channels rarely provide sufficient noise for proper concealment. The given data is also spread
 // across the available encoding bytes. The function returns an error on failure.
+//
+// When FEC is enabled (see WithFEC), data is first RS-encoded and a small fixed-size mode header
+// describing the FEC parameters is embedded ahead of the usual length header, so Reveal can recover
+// the original data even if the image is subsequently and partially corrupted.
+//
+// When a key is set (see WithKey), the mode header, length header and payload slots are visited in a
+// key-derived pseudo-random order rather than raster-scan, so the embedding location is not fixed and
+// statistical steganalysis of a linear layout no longer applies. A single keyed-mode flag byte followed
+// by the permutation nonce is always written first, in raster order, since the permutation cannot be
+// derived until the nonce has been read back.
+//
+// Callers that would otherwise need to materialize a large data slice up front, or that don't know
+// its length in advance, should use ConcealStream instead. It uses a different, simpler slot layout
+// (no spreading across the available capacity) so steganograms it produces are only revealed by
+// RevealStream, not by Reveal, and vice versa.
 func (s SteganoPNG) Conceal(data []byte, r io.Reader, w io.Writer) error {
 	log.Print("Conceal")
 	sourceImg, _, err := image.Decode(r)
@@ -131,18 +165,56 @@ func (s SteganoPNG) Conceal(data []byte, r io.Reader, w io.Writer) error {
 	width := bounds.Max.X - bounds.Min.X
 	height := bounds.Max.Y - bounds.Min.Y
 
-	hdata, err := s.headerBytes(len(data))
+	payload := data
+	if s.fec != nil {
+		payload = s.fec.encode(data)
+	}
+
+	modeHeader := encodeModeHeader(s.fec, len(data))
+	modeSlots := len(modeHeader) * 4
+
+	hdata, err := s.headerBytes(len(payload))
 	if err != nil {
 		return err
 	}
 
+	totalSlots := width * height * 3
+	prefixSlots := prefixLen * 4
 	cap := CalculateCapacity(width, height, 3, 2)
-	if len(data) > cap-len(hdata) {
-		return fmt.Errorf("%w: length (%v) capacity (%v)", stegano.ErrCapacityOverflow, len(data), cap)
+	if totalSlots < prefixSlots {
+		return fmt.Errorf("%w: length (%v) capacity (%v)", stegano.ErrCapacityOverflow, len(payload), cap)
+	}
+
+	prefix := make([]byte, prefixLen)
+	mapper := newSlotMapper(s.permKey)
+	if s.permKey != nil {
+		nonce, err := stegano.NewNonce()
+		if err != nil {
+			return err
+		}
+		prefix[0] = 1
+		copy(prefix[1:], nonce)
+		if err := mapper.build(nonce, totalSlots-prefixSlots); err != nil {
+			return fmt.Errorf("permutation: %w", err)
+		}
+	}
+
+	budget := cap - prefixLen - len(modeHeader) - len(hdata)
+	if len(payload) > budget {
+		return fmt.Errorf("%w: length (%v) capacity (%v)", stegano.ErrCapacityOverflow, len(payload), cap)
 	}
-	step := int(float64(cap-len(hdata)) / float64(len(data)) * 4)
+	step := int(float64(budget) / float64(len(payload)) * 4)
+	// step must be a multiple of 4: each payload byte's 4 encoding bits
+	// live at the start of its step-sized window, and in keyed mode the
+	// permutation operates on 4-slot groups, so a window boundary that
+	// splits a group would scatter a byte's bits across two permuted
+	// groups and make Reveal unable to reassemble it.
+	if step < 4 {
+		step = 4
+	}
+	step -= step % 4
 
-	log.Printf("indexbyte=%d header=%d data=%d capacity=%d step=%d", hdata[0], len(hdata), len(data), cap, step)
+	log.Printf("indexbyte=%d mode=%d header=%d data=%d capacity=%d step=%d", hdata[0], len(modeHeader), len(hdata), len(payload), cap, step)
 
 	outputImg := image.NewNRGBA(image.Rect(0, 0, width, height))
 
@@ -160,40 +232,49 @@ func (s SteganoPNG) Conceal(data []byte, r io.Reader, w io.Writer) error {
 			pxi := x + (y * width)
 			// channel
 			for ci := 0; ci < 3; ci++ {
-				// available encoding byte
-				abi := pxi*3 + ci
-				// data byte index, start bit index (on data byte)
+				// available encoding byte, in physical (raster) order
+				physical := pxi*3 + ci
+
+				// data byte index, start bit index (on data byte) and the
+				// slice the bit is sourced from: prefix, mode header,
+				// length header, or payload.
 				var dbi, sbi int
-				if abi < len(hdata)*4 {
-					// set indices for header data
-					dbi = abi / 4
-					sbi = (abi % 4) * 2
-				} else {
-					// set indices for content data
-					dbi = (abi - len(hdata)*4) / step
-					sbi = ((abi - len(hdata)*4) % step) * 2
+				var src []byte
+				switch {
+				case physical < prefixSlots:
+					src = prefix
+					dbi = physical / 4
+					sbi = (physical % 4) * 2
+				default:
+					// the prefix always stays in raster order; everything
+					// after it is visited in key-permuted order
+					abi := mapper.toLogical(physical - prefixSlots)
+					switch {
+					case abi < modeSlots:
+						src = modeHeader
+						dbi = abi / 4
+						sbi = (abi % 4) * 2
+					case abi < modeSlots+len(hdata)*4:
+						habi := abi - modeSlots
+						src = hdata
+						dbi = habi / 4
+						sbi = (habi % 4) * 2
+					default:
+						pabi := abi - modeSlots - len(hdata)*4
+						src = payload
+						dbi = pabi / step
+						sbi = (pabi % step) * 2
+					}
 				}
 
-				//log.Printf("Px: %04d Channel: %d ABi: %04d DBi: %04d", pxi, ci, abi, dbi)
-
 				// conceal data bits on available encoding byte
-				if sbi < 8 && dbi < len(data) {
-					//log.Printf(" [conceal]")
+				if sbi < 8 && dbi < len(src) {
 					// encoding bits (two least significant on available encoding byte)
 					for ebi := 0; ebi < 2; ebi++ {
 						// bit index (on data byte)
 						bi := sbi + ebi
 						// bit value (from bit mask - e.g. bi:3 0000 0001 -> 0000 1000 = 8)
-						var bit byte
-						if abi < len(hdata)*4 {
-							//log.Printf(" [header]")
-							bit = hdata[dbi] & (1 << uint8(bi))
-						} else {
-							//log.Printf(" [content]")
-							bit = data[dbi] & (1 << uint8(bi))
-						}
-
-						//log.Printf(" Bi: %d Bit: %02d", bi, bit)
+						bit := src[dbi] & (1 << uint8(bi))
 
 						switch bit {
 						case 0:
@@ -202,13 +283,7 @@ func (s SteganoPNG) Conceal(data []byte, r io.Reader, w io.Writer) error {
 							px[ci] |= uint8(ebi + 1) // set to 1
 						}
 					}
-				} /* else {
-					//log.Printf(" [randomize]")
-					n := rand.Intn(4)
-					//log.Printf(" %v -> ", px[ci])
-					px[ci] ^= uint8(n)
-					//log.Printf("%v", px[ci])
-				}*/
+				}
 			}
 
 			outputImg.Set(x, y, color.NRGBA{
@@ -229,10 +304,29 @@ func (s SteganoPNG) Conceal(data []byte, r io.Reader, w io.Writer) error {
 }
 
 // Reveal uncovers any steganographic data from the PNG image decoded from reader and writes
-// the output to the writer. It first decodes a mapped byte from the first available encoding byte
-// to determine the length of the subsequent bytes holding the content length. The content length is
-// decoded from the subsequent bytes whereafter the content is decoded from the entire data space.
+// the output to the writer. It first reads the fixed-size, always-raster-order prefix to learn
+// whether the image was concealed with a key and, if so, recover the permutation nonce; it then
+// rebuilds the same key-derived visitation order Conceal used (or falls back to raster order in
+// keyless mode) to decode the fixed-size mode header, which in turn reveals whether FEC was
+// applied and, if so, with what parity rate and original data length. It then decodes a mapped
+// byte from the first available slot after the mode header to determine the length of the
+// subsequent bytes holding the payload length, decodes the payload length from those bytes, and
+// finally decodes the payload from the remaining data space, RS-correcting it if FEC was applied.
 // The function returns an error on failure.
+//
+// Unlike Conceal, which has the whole header and payload available up front and so can write
+// their bits in any order, Reveal only learns the length header's own length - and so the
+// boundary between header and payload - once it has decoded the header's first byte. In keyless
+// mode that's safe to infer from a single raster-order scan, since logical and physical order
+// coincide. In keyed mode they don't: the key-derived visitation order can present payload slots
+// before the header has been fully read, and a scan that infers the header/payload boundary as
+// it goes would misread payload bits as header bits (or vice versa). Reveal therefore decodes
+// each byte by its logical index directly, via the mapper's logical-to-physical lookup, rather
+// than inferring stage boundaries from a single forward scan; this also still works for keyless
+// mode, since the mapper is the identity there.
+//
+// Reveal buffers the whole payload before returning it; for a steganogram concealed with
+// ConcealStream, use RevealStream instead to write it out as it is decoded.
 func (s SteganoPNG) Reveal(r io.Reader, w io.Writer) error {
 	log.Print("Reveal")
 	sourceImg, _, err := image.Decode(r)
@@ -248,99 +342,128 @@ func (s SteganoPNG) Reveal(r io.Reader, w io.Writer) error {
 
 	log.Printf("capacity=%d", cap)
 
-	// byte to rebuild
-	var dbyte byte = 0
-	var hdata []byte
-	var data []byte
-	step := 4
-	clen := 0
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b, _ := sourceImg.At(x, y).RGBA()
-			px := make([]byte, 3)
-			// RGB values are pre-multiplied and represented as uint32 to prevent blendfactor multiplication overflow,
-			// consequently they need to be normalized to a raw data byte (uint8).
-			px[0] = uint8(r / 256)
-			px[1] = uint8(g / 256)
-			px[2] = uint8(b / 256)
-
-			// pixel
-			pxi := x + (y * width)
-			// channel
-			for ci := 0; ci < 3; ci++ {
-				// available encoding byte
-				abi := pxi*3 + ci
+	totalSlots := width * height * 3
+	prefixSlots := prefixLen * 4
+
+	// readSlot returns the two-least-significant-bit value stored in the
+	// given physical (raster order) encoding slot.
+	readSlot := func(physical int) byte {
+		pxi := physical / 3
+		ci := physical % 3
+		x := bounds.Min.X + pxi%width
+		y := bounds.Min.Y + pxi/width
+		r, g, b, _ := sourceImg.At(x, y).RGBA()
+		switch ci {
+		case 0:
+			return uint8(r / 256)
+		case 1:
+			return uint8(g / 256)
+		default:
+			return uint8(b / 256)
+		}
+	}
 
-				var dbi, sbi int
-				if hdata == nil || abi < len(hdata)*4 {
-					// set indices for header data
-					dbi = abi / 4
-					sbi = (abi % 4) * 2
-				} else {
-					// data byte index
-					dbi = (abi - len(hdata)*4) / step
-					// start bit index (on data byte)
-					sbi = ((abi - len(hdata)*4) % step) * 2
-				}
+	// decodeBit extracts encoding bit ebi (0 or 1) from a slot value and
+	// places it at its original position bi in the data byte.
+	decodeBit := func(px byte, bi, ebi int) byte {
+		ebit := px & (1 << uint8(ebi))
+		return ebit << uint8(bi/2*2)
+	}
 
-				//log.Printf("Px: %04d Channel: %d ABi: %04d DBi: %04d", pxi, ci, abi, dbi)
+	// prefix is always raster order, since the permutation cannot be
+	// derived until the nonce it carries has been read back.
+	prefix := make([]byte, prefixLen)
+	for dbi := range prefix {
+		var dbyte byte
+		for sub := 0; sub < 4; sub++ {
+			px := readSlot(dbi*4 + sub)
+			sbi := sub * 2
+			for ebi := 0; ebi < 2; ebi++ {
+				dbyte |= decodeBit(px, sbi+ebi, ebi)
+			}
+		}
+		prefix[dbi] = dbyte
+	}
 
-				if sbi < 8 && (data == nil || dbi < len(data)) {
-					//log.Printf(" [reveal]")
-					for ebi := 0; ebi < 2; ebi++ {
-						// bit index (on data byte)
-						bi := sbi + ebi
+	mapper := newSlotMapper(s.permKey)
+	if prefix[0] != 0 {
+		if s.permKey == nil {
+			return fmt.Errorf("png: steganogram requires a key to reveal")
+		}
+		if err := mapper.build(prefix[1:], totalSlots-prefixSlots); err != nil {
+			return fmt.Errorf("permutation: %w", err)
+		}
+	}
 
-						// extract bit
-						ebit := px[ci] & (1 << uint8(ebi))
-						// get original bit value
-						bit := ebit << uint8(bi/2*2)
-
-						//log.Printf(" Bi: %d Bit: %02d", bi, bit)
-
-						// rebuild byte
-						dbyte |= bit
-
-						// dbyte complete
-						if bi == 7 {
-							if hdata == nil || abi < len(hdata)*4 {
-								//log.Printf(" [header]")
-								// first byte indicates content length byte size
-								if dbi == 0 {
-									// hmap provides index (+1 for length, +1 for byte size byte)
-									// dmap provides value (+1 for byte size byte)
-									hdata = make([]byte, s.hmap[dbyte]+1+1)
-									log.Printf("indexbyte=%d header=%d", dbyte, len(hdata))
-									//log.Printf(" content length encoded to %v byte(s) [%v]", s.hmap[dbyte], dbyte)
-								}
-								hdata[dbi] = dbyte
-								if dbi == len(hdata)-1 {
-									// content length data
-									cld := hdata[1:]
-									for i := 0; i < len(cld); i++ {
-										for ii := 0; ii < 8; ii++ {
-											tbit := cld[i] & (1 << uint(ii))
-											bit := uint(tbit) << uint(i*8)
-											clen |= int(bit)
-										}
-									}
-									data = make([]byte, clen)
-									step = int(float64(cap-len(hdata)) / float64(clen) * 4)
-									log.Printf("data=%d step=%d", clen, step)
-									//log.Printf(" content length is %v bytes", clen)
-								}
-							} else {
-								//log.Printf(" [content]")
-								data[dbi] = dbyte
-							}
-							// reset dbyte to prevent data shadowing
-							dbyte = 0
-						}
-					}
-				}
-				// TODO: verbose logging here
+	// decodeByte reads the 4 logical slots starting at abi - the 8 bits of
+	// one data byte, two per slot - via the mapper, regardless of where
+	// the permutation (or the identity mapping, in keyless mode) places
+	// them physically.
+	decodeByte := func(abi int) byte {
+		var dbyte byte
+		for sub := 0; sub < 4; sub++ {
+			physical := prefixSlots + mapper.toPhysical(abi+sub)
+			px := readSlot(physical)
+			sbi := sub * 2
+			for ebi := 0; ebi < 2; ebi++ {
+				dbyte |= decodeBit(px, sbi+ebi, ebi)
 			}
 		}
+		return dbyte
+	}
+
+	modeHeader := make([]byte, modeHeaderEncodedLen())
+	for dbi := range modeHeader {
+		modeHeader[dbi] = decodeByte(dbi * 4)
+	}
+	modeSlots := len(modeHeader) * 4
+
+	// the first header byte indicates the content length byte size, which
+	// gives the header's own total length (ByteCountFor + 1 for that byte
+	// itself, +1 for the index byte).
+	indexByte := decodeByte(modeSlots)
+	hdata := make([]byte, s.hcoder.ByteCountFor(indexByte)+1+1)
+	hdata[0] = indexByte
+	for dbi := 1; dbi < len(hdata); dbi++ {
+		hdata[dbi] = decodeByte(modeSlots + dbi*4)
+	}
+	log.Printf("indexbyte=%d header=%d", indexByte, len(hdata))
+
+	clen := 0
+	cld := hdata[1:]
+	for i := 0; i < len(cld); i++ {
+		for ii := 0; ii < 8; ii++ {
+			tbit := cld[i] & (1 << uint(ii))
+			clen |= int(uint(tbit) << uint(i*8))
+		}
+	}
+
+	data := make([]byte, clen)
+	if clen > 0 {
+		step := int(float64(cap-prefixLen-modeSlots/4-len(hdata)) / float64(clen) * 4)
+		if step < 4 {
+			step = 4
+		}
+		step -= step % 4
+		log.Printf("data=%d step=%d", clen, step)
+
+		payloadStart := modeSlots + len(hdata)*4
+		for dbi := 0; dbi < clen; dbi++ {
+			data[dbi] = decodeByte(payloadStart + dbi*step)
+		}
+	}
+
+	fec, origLen, err := decodeModeHeader(modeHeader)
+	if err != nil {
+		return fmt.Errorf("mode header: %w", err)
+	}
+
+	if fec != nil {
+		corrected, err := fec.decode(data, origLen)
+		if err != nil {
+			return fmt.Errorf("fec decode: %w", err)
+		}
+		data = corrected
 	}
 
 	n, err := w.Write(data)
@@ -0,0 +1,182 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package png
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	stdpng "image/png"
+	"testing"
+
+	"github.com/zanicar/stegano"
+)
+
+func makeCoverPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 7), G: uint8(y * 13), B: uint8(x + y), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := stdpng.Encode(&buf, img); err != nil {
+		t.Fatalf("encode cover: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestConcealRevealKeyless(t *testing.T) {
+	cover := makeCoverPNG(t, 64, 64)
+	data := []byte("keyless conceal/reveal must roundtrip")
+
+	s := New()
+	var out bytes.Buffer
+	if err := s.Conceal(data, bytes.NewReader(cover), &out); err != nil {
+		t.Fatalf("Conceal: %v", err)
+	}
+
+	var revealed bytes.Buffer
+	if err := s.Reveal(bytes.NewReader(out.Bytes()), &revealed); err != nil {
+		t.Fatalf("Reveal: %v", err)
+	}
+	if revealed.String() != string(data) {
+		t.Fatalf("roundtrip mismatch: got %q want %q", revealed.String(), string(data))
+	}
+}
+
+// TestConcealRevealKeyedVariedDimensions guards against the regression
+// where width*height*3 (the number of slots following the fixed prefix) was
+// not a multiple of 4, and slotMapper.build silently dropped the trailing
+// remainder from the permutation while Conceal/Reveal still visited it,
+// panicking with an out-of-range index. Most ordinary cover dimensions -
+// odd crops, thumbnails, hand-resized images - hit this.
+func TestConcealRevealKeyedVariedDimensions(t *testing.T) {
+	dims := [][2]int{
+		{101, 101},
+		{333, 250},
+		{99, 99},
+		{401, 301},
+		{64, 64},
+	}
+	for _, d := range dims {
+		w, h := d[0], d[1]
+		cover := makeCoverPNG(t, w, h)
+		data := []byte("keyed roundtrip must survive any cover dimensions")
+
+		s := New(WithKey([]byte("correct horse battery staple")))
+		var out bytes.Buffer
+		if err := s.Conceal(data, bytes.NewReader(cover), &out); err != nil {
+			t.Fatalf("%vx%v: Conceal: %v", w, h, err)
+		}
+
+		var revealed bytes.Buffer
+		if err := s.Reveal(bytes.NewReader(out.Bytes()), &revealed); err != nil {
+			t.Fatalf("%vx%v: Reveal: %v", w, h, err)
+		}
+		if revealed.String() != string(data) {
+			t.Fatalf("%vx%v: roundtrip mismatch: got %q want %q", w, h, revealed.String(), string(data))
+		}
+	}
+}
+
+func TestConcealRevealWithFEC(t *testing.T) {
+	cover := makeCoverPNG(t, 128, 128)
+	data := []byte("FEC-protected conceal/reveal must roundtrip")
+
+	s := New(WithFEC(8.0 / 128.0))
+	var out bytes.Buffer
+	if err := s.Conceal(data, bytes.NewReader(cover), &out); err != nil {
+		t.Fatalf("Conceal: %v", err)
+	}
+
+	var revealed bytes.Buffer
+	if err := s.Reveal(bytes.NewReader(out.Bytes()), &revealed); err != nil {
+		t.Fatalf("Reveal: %v", err)
+	}
+	if revealed.String() != string(data) {
+		t.Fatalf("roundtrip mismatch: got %q want %q", revealed.String(), string(data))
+	}
+}
+
+// TestNewClampsExtremeFECRate guards against the regression where
+// WithFEC(rate) for any rate above roughly 0.99 made New panic, since
+// parityShardsForRate did not bound the parity count to what a
+// fecDataShards-byte RS block can hold.
+func TestNewClampsExtremeFECRate(t *testing.T) {
+	s := New(WithFEC(1.0))
+	if s.fec == nil {
+		t.Fatalf("expected FEC to be enabled")
+	}
+}
+
+func TestConcealStreamRevealStreamSized(t *testing.T) {
+	cover := makeCoverPNG(t, 128, 128)
+	data := []byte("sized streaming payload must roundtrip")
+
+	s := New()
+	var out bytes.Buffer
+	if err := s.ConcealStream(bytes.NewReader(data), bytes.NewReader(cover), &out, int64(len(data))); err != nil {
+		t.Fatalf("ConcealStream: %v", err)
+	}
+
+	var revealed bytes.Buffer
+	if err := s.RevealStream(bytes.NewReader(out.Bytes()), &revealed); err != nil {
+		t.Fatalf("RevealStream: %v", err)
+	}
+	if revealed.String() != string(data) {
+		t.Fatalf("roundtrip mismatch: got %q want %q", revealed.String(), string(data))
+	}
+}
+
+func TestConcealStreamRevealStreamUnsized(t *testing.T) {
+	cover := makeCoverPNG(t, 128, 128)
+	data := []byte("unsized streaming payload, terminated rather than length-prefixed")
+
+	s := New()
+	var out bytes.Buffer
+	if err := s.ConcealStream(bytes.NewReader(data), bytes.NewReader(cover), &out, -1); err != nil {
+		t.Fatalf("ConcealStream: %v", err)
+	}
+
+	var revealed bytes.Buffer
+	if err := s.RevealStream(bytes.NewReader(out.Bytes()), &revealed); err != nil {
+		t.Fatalf("RevealStream: %v", err)
+	}
+	if revealed.String() != string(data) {
+		t.Fatalf("roundtrip mismatch: got %q want %q", revealed.String(), string(data))
+	}
+}
+
+// TestConcealKeyedCoverTooSmallForPrefix guards against the regression
+// where a cover too small to even hold the fixed raster-order prefix
+// (prefixLen*4 slots) made totalSlots-prefixSlots negative, which flowed
+// into slotMapper.build and then stegano.Permutation's make([]int, n),
+// panicking with "makeslice: len out of range" instead of reporting
+// ErrCapacityOverflow.
+func TestConcealKeyedCoverTooSmallForPrefix(t *testing.T) {
+	cover := makeCoverPNG(t, 4, 4)
+	data := []byte("x")
+
+	s := New(WithKey([]byte("k")))
+	var out bytes.Buffer
+	err := s.Conceal(data, bytes.NewReader(cover), &out)
+	if !errors.Is(err, stegano.ErrCapacityOverflow) {
+		t.Fatalf("expected ErrCapacityOverflow, got %v", err)
+	}
+}
+
+func TestConcealStreamKeyedCoverTooSmallForPrefix(t *testing.T) {
+	cover := makeCoverPNG(t, 4, 4)
+	data := []byte("x")
+
+	s := New(WithKey([]byte("k")))
+	var out bytes.Buffer
+	err := s.ConcealStream(bytes.NewReader(data), bytes.NewReader(cover), &out, int64(len(data)))
+	if !errors.Is(err, stegano.ErrCapacityOverflow) {
+		t.Fatalf("expected ErrCapacityOverflow, got %v", err)
+	}
+}
@@ -0,0 +1,311 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package png
+
+import (
+	crand "crypto/rand"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"log"
+
+	"github.com/zanicar/stegano"
+)
+
+var (
+	_ stegano.StreamStegano = &SteganoPNG{}
+)
+
+// ConcealStream conceals up to size bytes read from src into the bytes read
+// from cover and writes the result to out, using the same two-least-
+// significant-bit-per-channel layout as Conceal. If size is -1, the
+// payload's length is not known up front; a terminator-sentinel marker
+// (see stegano.StreamTerminator) is embedded after the payload instead of
+// a length header.
+//
+// Forward error correction (see WithFEC) requires the whole payload up
+// front to compute its parity shards, so ConcealStream does not support
+// it. Keyed concealment (see WithKey) requires random access across the
+// whole payload, since the key-derived visitation order is not sequential;
+// when a key is set, ConcealStream instead buffers src fully and delegates
+// to Conceal. Only the keyless path is truly bounded-memory: payload bytes
+// are pulled from src lazily, one at a time, as they are embedded.
+func (s SteganoPNG) ConcealStream(src, cover io.Reader, out io.Writer, size int64) error {
+	log.Print("ConcealStream")
+
+	if s.fec != nil {
+		return fmt.Errorf("png: streaming does not support FEC")
+	}
+
+	if s.permKey != nil {
+		var (
+			data []byte
+			err  error
+		)
+		if size < 0 {
+			data, err = ioutil.ReadAll(src)
+		} else {
+			data = make([]byte, size)
+			_, err = io.ReadFull(src, data)
+		}
+		if err != nil {
+			return fmt.Errorf("stream payload: %w", err)
+		}
+		return s.Conceal(data, cover, out)
+	}
+
+	sourceImg, _, err := image.Decode(cover)
+	if err != nil {
+		return fmt.Errorf("image decode: %w", err)
+	}
+
+	bounds := sourceImg.Bounds()
+	width := bounds.Max.X - bounds.Min.X
+	height := bounds.Max.Y - bounds.Min.Y
+
+	prefixSlots := stegano.StreamPrefixLen * 4
+
+	prefix := make([]byte, stegano.StreamPrefixLen)
+	var hdata []byte
+	var source *stegano.PayloadSource
+	if size < 0 {
+		nonce := make([]byte, stegano.TerminatorSize)
+		if _, err := crand.Read(nonce); err != nil {
+			return fmt.Errorf("terminator nonce: %w", err)
+		}
+		prefix[0] = stegano.StreamFlagTerminated
+		copy(prefix[1:], nonce)
+		source = stegano.NewTerminatedPayloadSource(src, stegano.StreamTerminator(nonce))
+	} else {
+		hdata, err = s.headerBytes(int(size))
+		if err != nil {
+			return err
+		}
+		source = stegano.NewSizedPayloadSource(src, size)
+	}
+	headerSlots := len(hdata) * 4
+
+	outputImg := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	lastDbi := -1
+	var lastVal byte
+	finished := false
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := sourceImg.At(x, y).RGBA()
+			px := make([]byte, 3)
+			px[0] = uint8(r / 256)
+			px[1] = uint8(g / 256)
+			px[2] = uint8(b / 256)
+
+			pxi := x + (y * width)
+			for ci := 0; ci < 3; ci++ {
+				physical := pxi*3 + ci
+
+				var dbi, sbi, stage int
+				var chunk []byte
+				switch {
+				case physical < prefixSlots:
+					stage = -1
+					chunk = prefix
+					dbi = physical / 4
+					sbi = (physical % 4) * 2
+				default:
+					abi := physical - prefixSlots
+					switch {
+					case abi < headerSlots:
+						stage = 0
+						chunk = hdata
+						dbi = abi / 4
+						sbi = (abi % 4) * 2
+					default:
+						stage = 1
+						pabi := abi - headerSlots
+						dbi = pabi / 4
+						sbi = (pabi % 4) * 2
+					}
+				}
+
+				if stage == 1 {
+					if !finished && dbi != lastDbi {
+						val, ok, err := source.Next()
+						if err != nil {
+							return err
+						}
+						if !ok {
+							finished = true
+						} else {
+							lastVal = val
+							lastDbi = dbi
+						}
+					}
+					if finished {
+						continue
+					}
+					chunk = []byte{lastVal}
+					dbi = 0
+				}
+
+				if sbi < 8 && dbi < len(chunk) {
+					for ebi := 0; ebi < 2; ebi++ {
+						bi := sbi + ebi
+						bit := chunk[dbi] & (1 << uint8(bi))
+						switch bit {
+						case 0:
+							px[ci] &^= uint8(ebi + 1)
+						default:
+							px[ci] |= uint8(ebi + 1)
+						}
+					}
+				}
+			}
+
+			outputImg.Set(x, y, color.NRGBA{
+				R: uint8(px[0]),
+				G: uint8(px[1]),
+				B: uint8(px[2]),
+				A: uint8(a / 256),
+			})
+		}
+	}
+
+	if !finished {
+		return fmt.Errorf("%w: streaming payload did not fit in cover capacity", stegano.ErrCapacityOverflow)
+	}
+
+	if err := png.Encode(out, outputImg); err != nil {
+		return err
+	}
+
+	log.Print("streaming data concealed")
+	return nil
+}
+
+// RevealStream uncovers data concealed by ConcealStream from the PNG image
+// read from cover and writes it to dst as it is decoded, rather than
+// buffering the whole payload first. When s was configured with WithKey,
+// RevealStream delegates to Reveal, since ConcealStream does the same for
+// keyed concealment.
+func (s SteganoPNG) RevealStream(cover io.Reader, dst io.Writer) error {
+	log.Print("RevealStream")
+
+	if s.permKey != nil {
+		return s.Reveal(cover, dst)
+	}
+
+	sourceImg, _, err := image.Decode(cover)
+	if err != nil {
+		return err
+	}
+
+	bounds := sourceImg.Bounds()
+	width := bounds.Max.X - bounds.Min.X
+
+	prefixSlots := stegano.StreamPrefixLen * 4
+
+	prefix := make([]byte, stegano.StreamPrefixLen)
+	var hdata []byte
+	var sink *stegano.PayloadSink
+	headerSlots := -1 // unknown until the prefix's flag byte is read
+	clen := 0
+
+	var dbyte byte
+	done := false
+
+	for y := bounds.Min.Y; y < bounds.Max.Y && !done; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X && !done; x++ {
+			r, g, b, _ := sourceImg.At(x, y).RGBA()
+			px := make([]byte, 3)
+			px[0] = uint8(r / 256)
+			px[1] = uint8(g / 256)
+			px[2] = uint8(b / 256)
+
+			pxi := x + (y * width)
+			for ci := 0; ci < 3 && !done; ci++ {
+				physical := pxi*3 + ci
+
+				var dbi, sbi, stage int
+				switch {
+				case physical < prefixSlots:
+					stage = -1
+					dbi = physical / 4
+					sbi = (physical % 4) * 2
+				default:
+					abi := physical - prefixSlots
+					if headerSlots < 0 || abi < headerSlots {
+						stage = 0
+						dbi = abi / 4
+						sbi = (abi % 4) * 2
+					} else {
+						stage = 1
+						pabi := abi - headerSlots
+						dbi = pabi / 4
+						sbi = (pabi % 4) * 2
+					}
+				}
+
+				for ebi := 0; ebi < 2; ebi++ {
+					bi := sbi + ebi
+					ebit := px[ci] & (1 << uint8(ebi))
+					bit := ebit << uint8(bi/2*2)
+					dbyte |= bit
+
+					if bi == 7 {
+						switch stage {
+						case -1:
+							prefix[dbi] = dbyte
+							if dbi == stegano.StreamPrefixLen-1 && prefix[0]&stegano.StreamFlagTerminated != 0 {
+								headerSlots = 0
+								terminator := stegano.StreamTerminator(prefix[1:])
+								sink = stegano.NewTerminatedPayloadSink(dst, terminator)
+							}
+						case 0:
+							if hdata == nil {
+								hdata = make([]byte, s.hcoder.ByteCountFor(dbyte)+1+1)
+							}
+							hdata[dbi] = dbyte
+							if dbi == len(hdata)-1 {
+								cld := hdata[1:]
+								for i := 0; i < len(cld); i++ {
+									for ii := 0; ii < 8; ii++ {
+										tbit := cld[i] & (1 << uint(ii))
+										clen |= int(uint(tbit) << uint(i*8))
+									}
+								}
+								headerSlots = len(hdata) * 4
+								if clen == 0 {
+									done = true
+								} else {
+									sink = stegano.NewSizedPayloadSink(dst, int64(clen))
+								}
+							}
+						default:
+							if sink != nil {
+								sdone, err := sink.Push(dbyte)
+								if err != nil {
+									return err
+								}
+								if sdone {
+									done = true
+								}
+							}
+						}
+						dbyte = 0
+					}
+				}
+			}
+		}
+	}
+
+	if !done {
+		return fmt.Errorf("stegano: no concealed data found")
+	}
+
+	log.Print("streaming data revealed")
+	return nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package rs
+
+import "fmt"
+
+// berlekampMassey finds the shortest linear feedback shift register (the
+// error locator polynomial) that generates the given syndrome sequence.
+// The returned polynomial is ordered from the constant term upward, i.e.
+// errLoc[0] == 1.
+func berlekampMassey(syn []byte) []byte {
+	n := len(syn)
+	c := make([]byte, n+1)
+	b := make([]byte, n+1)
+	c[0], b[0] = 1, 1
+
+	l, m := 0, 1
+	var bCoef byte = 1
+
+	for i := 0; i < n; i++ {
+		delta := syn[i]
+		for j := 1; j <= l; j++ {
+			delta ^= gfMul(c[j], syn[i-j])
+		}
+
+		switch {
+		case delta == 0:
+			m++
+		case 2*l <= i:
+			t := append([]byte(nil), c...)
+			coef := gfDiv(delta, bCoef)
+			for j := 0; j+m < len(c); j++ {
+				c[j+m] ^= gfMul(coef, b[j])
+			}
+			l = i + 1 - l
+			b = t
+			bCoef = delta
+			m = 1
+		default:
+			coef := gfDiv(delta, bCoef)
+			for j := 0; j+m < len(c); j++ {
+				c[j+m] ^= gfMul(coef, b[j])
+			}
+			m++
+		}
+	}
+
+	return c[:l+1]
+}
+
+// chienSearch finds the roots of the error locator polynomial by brute-force
+// evaluation at every field element, returning the corresponding error
+// positions in the block (0 = first/highest-degree byte). The returned
+// slice holds one position per located error, so its length equals the
+// locator's degree, len(errLoc)-1 (the constant term is not a position);
+// callers should use len(positions) as the error count.
+func chienSearch(errLoc []byte, blockLen int) ([]int, error) {
+	var positions []int
+	for j := 0; j < 255; j++ {
+		// evaluate errLoc at alpha^(-j), i.e. alpha^(255-j)
+		x := gfExp(2, (255-j)%255)
+		var v byte
+		xp := byte(1)
+		for _, coef := range errLoc {
+			v ^= gfMul(coef, xp)
+			xp = gfMul(xp, x)
+		}
+		if v == 0 {
+			pos := blockLen - 1 - j
+			if pos < 0 || pos >= blockLen {
+				continue
+			}
+			positions = append(positions, pos)
+		}
+	}
+
+	if len(positions) != len(errLoc)-1 {
+		return nil, fmt.Errorf("rs: error locator degree (%v) does not match roots found (%v)", len(errLoc)-1, len(positions))
+	}
+
+	return positions, nil
+}
+
+// forneyCorrect computes error magnitudes via the Forney algorithm and XORs
+// them into block at the given positions.
+func forneyCorrect(block []byte, syn []byte, errLoc []byte, positions []int) error {
+	blockLen := len(block)
+
+	// error evaluator polynomial: omega(x) = [S(x) * errLoc(x)] mod x^(len(syn))
+	synPoly := append([]byte(nil), syn...)
+	// syn is ordered S_0..S_(n-1); treat as polynomial with syn[0] as
+	// constant term for the purposes of this convolution.
+	omega := polyMulLow(synPoly, errLoc)
+	if len(omega) > len(syn) {
+		omega = omega[:len(syn)]
+	}
+
+	// derivative of errLoc (formal derivative over GF(2^m) keeps only odd
+	// degree terms, since even-degree derivatives vanish in characteristic 2)
+	var deriv []byte
+	for i := 1; i < len(errLoc); i += 2 {
+		deriv = append(deriv, errLoc[i])
+	}
+
+	for _, pos := range positions {
+		j := blockLen - 1 - pos
+		xInv := gfExp(2, (255-j)%255)
+
+		var num byte
+		xp := byte(1)
+		for _, coef := range omega {
+			num ^= gfMul(coef, xp)
+			xp = gfMul(xp, xInv)
+		}
+
+		var den byte
+		xp = byte(1)
+		x2 := gfMul(xInv, xInv)
+		for _, coef := range deriv {
+			den ^= gfMul(coef, xp)
+			xp = gfMul(xp, x2)
+		}
+		if den == 0 {
+			return fmt.Errorf("rs: forney: zero derivative at position %v", pos)
+		}
+
+		x := gfExp(2, j)
+		magnitude := gfMul(x, gfDiv(num, den))
+		block[pos] ^= magnitude
+	}
+
+	return nil
+}
+
+// polyMulLow multiplies two polynomials given with the constant term first
+// (the opposite convention of polyMul, which is high-degree first).
+func polyMulLow(p, q []byte) []byte {
+	r := make([]byte, len(p)+len(q)-1)
+	for i, pc := range p {
+		if pc == 0 {
+			continue
+		}
+		for j, qc := range q {
+			r[i+j] ^= gfMul(pc, qc)
+		}
+	}
+	return r
+}
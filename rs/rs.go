@@ -0,0 +1,207 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+// Package rs implements a systematic Reed-Solomon error correcting code over
+// GF(256), suitable for protecting small fixed-size blocks of bytes against
+// random symbol errors (as opposed to erasures with known positions).
+package rs
+
+import "fmt"
+
+// field is the GF(256) arithmetic used throughout the package. It is built
+// around the primitive polynomial x^8 + x^4 + x^3 + x^2 + 1 (0x11d), the
+// same field used by QR codes and many other byte oriented RS codes.
+const primitivePoly = 0x11d
+
+var (
+	expTable [512]byte
+	logTable [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		expTable[i] = byte(x)
+		logTable[byte(x)] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= primitivePoly
+		}
+	}
+	for i := 255; i < 512; i++ {
+		expTable[i] = expTable[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	if b == 0 {
+		panic("rs: division by zero")
+	}
+	return expTable[(int(logTable[a])+255-int(logTable[b]))%255]
+}
+
+func gfExp(a byte, n int) byte {
+	if a == 0 {
+		if n == 0 {
+			return 1
+		}
+		return 0
+	}
+	return expTable[(int(logTable[a])*n)%255]
+}
+
+// polyMul multiplies two polynomials, given as coefficient slices from
+// highest to lowest degree.
+func polyMul(p, q []byte) []byte {
+	r := make([]byte, len(p)+len(q)-1)
+	for i, pc := range p {
+		if pc == 0 {
+			continue
+		}
+		for j, qc := range q {
+			r[i+j] ^= gfMul(pc, qc)
+		}
+	}
+	return r
+}
+
+// generator returns the generator polynomial for a code with the given
+// number of parity symbols, g(x) = (x-a^0)(x-a^1)...(x-a^(parity-1)).
+func generator(parity int) []byte {
+	g := []byte{1}
+	for i := 0; i < parity; i++ {
+		g = polyMul(g, []byte{1, gfExp(2, i)})
+	}
+	return g
+}
+
+// RS implements a systematic RS(dataShards+parityShards, dataShards) code
+// over GF(256). It corrects up to parityShards/2 symbol errors per block.
+type RS struct {
+	dataShards   int
+	parityShards int
+	gen          []byte
+}
+
+// New returns an RS codec encoding dataShards bytes of payload into
+// dataShards+parityShards bytes of codeword. The total block size must not
+// exceed 255 bytes, which is the largest block GF(256) symbols can index.
+func New(dataShards, parityShards int) (*RS, error) {
+	if dataShards <= 0 || parityShards <= 0 {
+		return nil, fmt.Errorf("rs: shard counts must be positive (data=%v parity=%v)", dataShards, parityShards)
+	}
+	if dataShards+parityShards > 255 {
+		return nil, fmt.Errorf("rs: block size (%v) exceeds 255", dataShards+parityShards)
+	}
+	return &RS{
+		dataShards:   dataShards,
+		parityShards: parityShards,
+		gen:          generator(parityShards),
+	}, nil
+}
+
+// DataShards returns the number of data bytes per block.
+func (c *RS) DataShards() int { return c.dataShards }
+
+// ParityShards returns the number of parity bytes per block.
+func (c *RS) ParityShards() int { return c.parityShards }
+
+// BlockSize returns the total number of bytes (data and parity) per block.
+func (c *RS) BlockSize() int { return c.dataShards + c.parityShards }
+
+// Encode returns the systematic codeword for a single block of data, which
+// must be exactly DataShards bytes long (callers pad short final blocks).
+func (c *RS) Encode(data []byte) ([]byte, error) {
+	if len(data) != c.dataShards {
+		return nil, fmt.Errorf("rs: encode expects %v bytes, got %v", c.dataShards, len(data))
+	}
+
+	// shift message left by parityShards (multiply by x^parityShards) and
+	// reduce modulo the generator polynomial; the remainder is the parity.
+	msg := make([]byte, c.dataShards+c.parityShards)
+	copy(msg, data)
+
+	rem := make([]byte, len(msg))
+	copy(rem, msg)
+	for i := 0; i < c.dataShards; i++ {
+		coef := rem[i]
+		if coef == 0 {
+			continue
+		}
+		for j, gc := range c.gen {
+			rem[i+j] ^= gfMul(gc, coef)
+		}
+	}
+
+	block := make([]byte, c.dataShards+c.parityShards)
+	copy(block, data)
+	copy(block[c.dataShards:], rem[c.dataShards:])
+	return block, nil
+}
+
+// Decode corrects up to ParityShards/2 symbol errors in block (which must be
+// BlockSize bytes long) and returns the recovered data portion together with
+// the number of errors that were corrected. It returns an error if the block
+// contains more errors than the code can correct.
+func (c *RS) Decode(block []byte) ([]byte, int, error) {
+	if len(block) != c.dataShards+c.parityShards {
+		return nil, 0, fmt.Errorf("rs: decode expects %v bytes, got %v", c.dataShards+c.parityShards, len(block))
+	}
+
+	syn := c.syndromes(block)
+	allZero := true
+	for _, s := range syn {
+		if s != 0 {
+			allZero = false
+			break
+		}
+	}
+	if allZero {
+		data := make([]byte, c.dataShards)
+		copy(data, block[:c.dataShards])
+		return data, 0, nil
+	}
+
+	errLoc := berlekampMassey(syn)
+	positions, err := chienSearch(errLoc, len(block))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(positions) > c.parityShards/2 {
+		return nil, 0, fmt.Errorf("rs: too many errors to correct (%v found, max %v)", len(positions), c.parityShards/2)
+	}
+
+	corrected := append([]byte(nil), block...)
+	if err := forneyCorrect(corrected, syn, errLoc, positions); err != nil {
+		return nil, 0, err
+	}
+
+	data := make([]byte, c.dataShards)
+	copy(data, corrected[:c.dataShards])
+	return data, len(positions), nil
+}
+
+// syndromes evaluates the received block at each root of the generator
+// polynomial; all zero syndromes indicate an (assumed) error free block.
+func (c *RS) syndromes(block []byte) []byte {
+	syn := make([]byte, c.parityShards)
+	for i := 0; i < c.parityShards; i++ {
+		var s byte
+		root := gfExp(2, i)
+		for _, coef := range block {
+			s = gfMul(s, root) ^ coef
+		}
+		syn[i] = s
+	}
+	return syn
+}
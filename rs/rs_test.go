@@ -0,0 +1,143 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package rs
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func mustCodec(t *testing.T, dataShards, parityShards int) *RS {
+	t.Helper()
+	c, err := New(dataShards, parityShards)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return c
+}
+
+func TestEncodeDecodeNoErrors(t *testing.T) {
+	c := mustCodec(t, 16, 8)
+	data := make([]byte, 16)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	block, err := c.Encode(data)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	got, n, err := c.Decode(block)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if n != 0 {
+		t.Fatalf("expected 0 corrections, got %v", n)
+	}
+	if string(got) != string(data) {
+		t.Fatalf("roundtrip mismatch: got %v want %v", got, data)
+	}
+}
+
+func TestDecodeCorrectsWithinCapacity(t *testing.T) {
+	c := mustCodec(t, 128, 8)
+	data := make([]byte, 128)
+	for i := range data {
+		data[i] = byte(i * 3)
+	}
+
+	block, err := c.Encode(data)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	maxErrors := c.ParityShards() / 2
+	corrupted := append([]byte(nil), block...)
+	positions := rng.Perm(len(corrupted))[:maxErrors]
+	for _, pos := range positions {
+		corrupted[pos] ^= byte(1 + rng.Intn(255))
+	}
+
+	got, n, err := c.Decode(corrupted)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if n != maxErrors {
+		t.Fatalf("expected %v corrections, got %v", maxErrors, n)
+	}
+	if string(got) != string(data) {
+		t.Fatalf("roundtrip mismatch: got %v want %v", got, data)
+	}
+}
+
+// TestDecodeRejectsBeyondCapacity guards against the regression where
+// chienSearch's error count (len(positions)) was miscompared with an
+// extraneous -1, letting a block with exactly one error more than the code
+// can correct through as if it had decoded cleanly, with silently wrong
+// data. Blocks with parityShards/2+1 errors, corrupted with each of the
+// seeds below, must be rejected.
+func TestDecodeRejectsBeyondCapacity(t *testing.T) {
+	c := mustCodec(t, 128, 8)
+	data := make([]byte, 128)
+	for i := range data {
+		data[i] = byte(i * 5)
+	}
+
+	block, err := c.Encode(data)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	tooMany := c.ParityShards()/2 + 1
+	for _, seed := range []int64{326, 436, 566} {
+		rng := rand.New(rand.NewSource(seed))
+		corrupted := append([]byte(nil), block...)
+		positions := rng.Perm(len(corrupted))[:tooMany]
+		for _, pos := range positions {
+			corrupted[pos] ^= byte(1 + rng.Intn(255))
+		}
+
+		got, _, err := c.Decode(corrupted)
+		if err == nil {
+			t.Fatalf("seed %v: expected error for %v errors (max %v), got nil with data %v", seed, tooMany, c.ParityShards()/2, got)
+		}
+	}
+}
+
+func TestDecodeAllZeroSyndromes(t *testing.T) {
+	c := mustCodec(t, 8, 4)
+	data := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	block, err := c.Encode(data)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	got, n, err := c.Decode(block)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if n != 0 {
+		t.Fatalf("expected 0 corrections on a clean block, got %v", n)
+	}
+	if string(got) != string(data) {
+		t.Fatalf("roundtrip mismatch: got %v want %v", got, data)
+	}
+}
+
+func TestNewRejectsOversizedBlock(t *testing.T) {
+	if _, err := New(200, 56); err == nil {
+		t.Fatalf("expected error for block size 256, got nil")
+	}
+}
+
+func TestNewRejectsNonPositiveShards(t *testing.T) {
+	if _, err := New(0, 8); err == nil {
+		t.Fatalf("expected error for zero data shards, got nil")
+	}
+	if _, err := New(8, 0); err == nil {
+		t.Fatalf("expected error for zero parity shards, got nil")
+	}
+}
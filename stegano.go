@@ -40,3 +40,16 @@ type Concealer interface {
 type Revealer interface {
 	Reveal(reader io.Reader, writer io.Writer) error
 }
+
+// Codec is the interface that groups optional payload transformations
+// applied before concealment and reversed after revelation, such as
+// forward error correction. Implementations must be reversible: for any
+// data, Decode(Encode(data)) must reproduce data exactly when Encode's
+// output has not been corrupted beyond the codec's tolerance.
+type Codec interface {
+	// Encode transforms data before it is concealed.
+	Encode(data []byte) ([]byte, error)
+
+	// Decode reverses Encode, recovering the original data.
+	Decode(data []byte) ([]byte, error)
+}
@@ -0,0 +1,184 @@
+// Copyright 2018 Zanicar. All rights reserved.
+// Utilizes a BSD-3 license. Refer to the included LICENSE file for details.
+
+package stegano
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+// TerminatorSize is the size, in bytes, of the end-of-stream marker written
+// in place of a length header when a streaming Conceal is not given the
+// payload's size up front.
+const TerminatorSize = 16
+
+// StreamPrefixLen is the size, in bytes, of the fixed, always-first prefix a
+// streaming Conceal writes ahead of its header and payload: one flag byte
+// (see StreamFlagTerminated) followed by a terminator nonce, the latter
+// meaningful only when concealing with an unknown size.
+const StreamPrefixLen = 1 + TerminatorSize
+
+// StreamFlagTerminated, set in a streaming prefix's flag byte, means the
+// steganogram was concealed without a known size and ends in a
+// terminator-sentinel marker instead of a length header.
+const StreamFlagTerminated = 1 << 0
+
+// StreamTerminator derives the end-of-stream marker a streaming Conceal
+// appends after the payload when its length isn't known up front, and a
+// streaming Reveal watches for to know where the payload ends. It is an
+// HMAC-SHA256 tag over a fixed label, keyed by a fresh random nonce so an
+// attacker who doesn't know the nonce cannot predict or plant it inside the
+// payload itself.
+func StreamTerminator(nonce []byte) []byte {
+	mac := hmac.New(sha256.New, nonce)
+	mac.Write([]byte("stegano-stream-eos"))
+	return mac.Sum(nil)[:TerminatorSize]
+}
+
+// StreamConcealer is the interface that wraps the ConcealStream method.
+//
+// ConcealStream conceals up to size bytes read from src into the bytes read
+// from cover and writes the result to out, pulling src lazily rather than
+// requiring the caller to materialize the payload in memory first. If size
+// is -1, the payload's length is not known up front; implementations must
+// then embed a terminator-sentinel marker (see StreamTerminator) after the
+// payload instead of a length header.
+type StreamConcealer interface {
+	ConcealStream(src, cover io.Reader, out io.Writer, size int64) error
+}
+
+// StreamRevealer is the interface that wraps the RevealStream method.
+//
+// RevealStream reveals the underlying data from cover and writes it to dst
+// as it is decoded, rather than buffering the whole payload first.
+type StreamRevealer interface {
+	RevealStream(cover io.Reader, dst io.Writer) error
+}
+
+// StreamStegano is the interface that groups the streaming ConcealStream and
+// RevealStream methods. It is satisfied in addition to, not instead of,
+// Stegano: implementations keep their existing []byte-oriented Conceal and
+// Reveal methods alongside it. Those are separate implementations, not
+// wrappers around the streaming methods, and use their own slot layout, so
+// a steganogram written by one pair is not read back by the other.
+type StreamStegano interface {
+	StreamConcealer
+	StreamRevealer
+}
+
+// PayloadSource lazily supplies the bytes a streaming Conceal embeds, in the
+// same order they are embedded, so the payload never needs to be
+// materialized as a single []byte. This keeps a streaming Conceal's memory
+// use independent of the payload's total size, provided the implementation
+// visits payload bytes in the order PayloadSource yields them.
+type PayloadSource struct {
+	r          io.Reader
+	remaining  int64
+	terminator []byte
+	eof        bool
+	tailPos    int
+}
+
+// NewSizedPayloadSource returns a PayloadSource that yields exactly size
+// bytes read from r.
+func NewSizedPayloadSource(r io.Reader, size int64) *PayloadSource {
+	return &PayloadSource{r: r, remaining: size}
+}
+
+// NewTerminatedPayloadSource returns a PayloadSource that yields bytes read
+// from r until it is exhausted, followed by terminator, for use when the
+// payload's length isn't known up front.
+func NewTerminatedPayloadSource(r io.Reader, terminator []byte) *PayloadSource {
+	return &PayloadSource{r: r, terminator: terminator}
+}
+
+// Next returns the next payload byte. ok is false once the source is
+// exhausted: remaining reached zero in fixed-size mode, or terminator's
+// bytes have all been returned in terminator mode.
+func (p *PayloadSource) Next() (b byte, ok bool, err error) {
+	if p.terminator == nil {
+		if p.remaining <= 0 {
+			return 0, false, nil
+		}
+		var buf [1]byte
+		if _, err := io.ReadFull(p.r, buf[:]); err != nil {
+			return 0, false, fmt.Errorf("stegano: payload source: %w", err)
+		}
+		p.remaining--
+		return buf[0], true, nil
+	}
+
+	if !p.eof {
+		var buf [1]byte
+		_, err := io.ReadFull(p.r, buf[:])
+		switch err {
+		case nil:
+			return buf[0], true, nil
+		case io.EOF:
+			p.eof = true
+		default:
+			return 0, false, fmt.Errorf("stegano: payload source: %w", err)
+		}
+	}
+	if p.tailPos < len(p.terminator) {
+		b := p.terminator[p.tailPos]
+		p.tailPos++
+		return b, true, nil
+	}
+	return 0, false, nil
+}
+
+// PayloadSink accepts payload bytes decoded by a streaming Reveal, writing
+// them to the underlying writer as soon as they can no longer be part of
+// the end-of-stream terminator, so Reveal never needs to buffer the whole
+// payload either.
+type PayloadSink struct {
+	w          io.Writer
+	remaining  int64
+	terminator []byte
+	window     []byte
+}
+
+// NewSizedPayloadSink returns a PayloadSink that writes exactly size bytes
+// to w.
+func NewSizedPayloadSink(w io.Writer, size int64) *PayloadSink {
+	return &PayloadSink{w: w, remaining: size}
+}
+
+// NewTerminatedPayloadSink returns a PayloadSink that writes bytes to w
+// until it recognizes terminator in the bytes pushed to it, holding back
+// at most len(terminator) bytes at a time so it can tell whether they're
+// payload or the terminator itself.
+func NewTerminatedPayloadSink(w io.Writer, terminator []byte) *PayloadSink {
+	return &PayloadSink{w: w, terminator: terminator, window: make([]byte, 0, len(terminator))}
+}
+
+// Push accepts the next decoded payload byte. done is true once the sink
+// has received everything it needs: size bytes in fixed-size mode, or the
+// terminator sequence in terminator mode (the terminator's own bytes are
+// consumed, not written to the underlying writer).
+func (p *PayloadSink) Push(b byte) (done bool, err error) {
+	if p.terminator == nil {
+		if p.remaining <= 0 {
+			return true, nil
+		}
+		if _, err := p.w.Write([]byte{b}); err != nil {
+			return false, err
+		}
+		p.remaining--
+		return p.remaining == 0, nil
+	}
+
+	p.window = append(p.window, b)
+	if len(p.window) > len(p.terminator) {
+		if _, err := p.w.Write(p.window[:1]); err != nil {
+			return false, err
+		}
+		p.window = p.window[1:]
+	}
+	return len(p.window) == len(p.terminator) && bytes.Equal(p.window, p.terminator), nil
+}